@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+//accessPoint represents an entrance or exit of the carpark, located next to a slot
+type accessPoint struct {
+	position int         //Slot next to the access point
+	distance map[int]int //Distance to each slot which is not counted in slots from the position
+}
+
+//distanceTo returns the distance from the access point to a slot, which is counted in slots unless set otherwise
+func (point *accessPoint) distanceTo(slotNo int) int {
+	if distance, ok := point.distance[slotNo]; ok {
+		return distance
+	}
+	if slotNo < point.position {
+		return point.position - slotNo
+	}
+	return slotNo - point.position
+}
+
+//nearestTo returns the slot nearest to an access point, preferring the lowest numbered slot on a tie
+func nearestTo(point *accessPoint, slots []int) int {
+	nearest := slots[0]
+	for _, slotNo := range slots[1:] {
+		if point.distanceTo(slotNo) < point.distanceTo(nearest) {
+			nearest = slotNo
+		}
+	}
+	return nearest
+}
+
+//Add a named entrance next to the given slot
+func (carpark *Carpark) addEntrance(name string, slotNo int) error {
+	if err := carpark.checkAccessPoint(name, slotNo); err != nil {
+		return err
+	}
+	carpark.entrances[name] = &accessPoint{position: slotNo, distance: make(map[int]int)}
+	return nil
+}
+
+//Add a named exit next to the given slot
+func (carpark *Carpark) addExit(name string, slotNo int) error {
+	if err := carpark.checkAccessPoint(name, slotNo); err != nil {
+		return err
+	}
+	carpark.exits[name] = &accessPoint{position: slotNo, distance: make(map[int]int)}
+	return nil
+}
+
+//Check that a new access point has an unused name and is next to a slot of the carpark
+func (carpark *Carpark) checkAccessPoint(name string, slotNo int) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	if slotNo < 1 || slotNo > carpark.maxSlot {
+		return errors.New("Invalid slot number")
+	}
+	if _, ok := carpark.accessPoint(name); ok {
+		return fmt.Errorf("Gate %v already exists", name)
+	}
+	return nil
+}
+
+//Retrieve the entrance or exit with the given name
+func (carpark *Carpark) accessPoint(name string) (*accessPoint, bool) {
+	if point, ok := carpark.entrances[name]; ok {
+		return point, true
+	}
+	point, ok := carpark.exits[name]
+	return point, ok
+}
+
+//Set the distance from an entrance or exit to a slot
+func (carpark *Carpark) setDistance(name string, slotNo int, distance int) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	point, ok := carpark.accessPoint(name)
+	if !ok {
+		return fmt.Errorf("Unknown gate %v", name)
+	}
+	if slotNo < 1 || slotNo > carpark.maxSlot {
+		return errors.New("Invalid slot number")
+	}
+	if distance < 0 {
+		return errors.New("Invalid distance")
+	}
+	point.distance[slotNo] = distance
+	return nil
+}
+
+//Drop the distances to slots removed from the carpark, moving access points next to removed slots to the last slot
+func (carpark *Carpark) trimAccessPoints(maxSlot int) {
+	for _, points := range []map[string]*accessPoint{carpark.entrances, carpark.exits} {
+		for _, point := range points {
+			if point.position > maxSlot {
+				point.position = maxSlot
+			}
+			for slotNo := range point.distance {
+				if slotNo > maxSlot {
+					delete(point.distance, slotNo)
+				}
+			}
+		}
+	}
+}
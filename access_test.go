@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_accessPoint_distanceTo(t *testing.T) {
+	point := &accessPoint{position: 5, distance: map[int]int{9: 1}}
+	tests := []struct {
+		name   string
+		slotNo int
+		want   int
+	}{
+		{name: "Slot next to the access point", slotNo: 5, want: 0},
+		{name: "Slot below the access point", slotNo: 2, want: 3},
+		{name: "Slot above the access point", slotNo: 7, want: 2},
+		{name: "Slot with a set distance", slotNo: 9, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := point.distanceTo(tt.slotNo); got != tt.want {
+				t.Errorf("accessPoint.distanceTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCarpark_setDistance(t *testing.T) {
+	type args struct {
+		name     string
+		slotNo   int
+		distance int
+	}
+	tests := []struct {
+		name         string
+		carpark      *Carpark
+		args         args
+		wantErr      bool
+		wantDistance map[int]int
+	}{
+		{name: "Carpark not initialized",
+			carpark: &Carpark{},
+			args:    args{name: "A", slotNo: 1, distance: 1},
+			wantErr: true,
+		},
+		{name: "Distance from an entrance",
+			carpark:      &Carpark{Map: values().map0, maxSlot: 10, entrances: map[string]*accessPoint{"A": {position: 1, distance: map[int]int{}}}},
+			args:         args{name: "A", slotNo: 8, distance: 2},
+			wantErr:      false,
+			wantDistance: map[int]int{8: 2},
+		},
+		{name: "Distance from an exit",
+			carpark:      &Carpark{Map: values().map0, maxSlot: 10, exits: map[string]*accessPoint{"A": {position: 1, distance: map[int]int{}}}},
+			args:         args{name: "A", slotNo: 8, distance: 2},
+			wantErr:      false,
+			wantDistance: map[int]int{8: 2},
+		},
+		{name: "Unknown gate",
+			carpark: &Carpark{Map: values().map0, maxSlot: 10},
+			args:    args{name: "A", slotNo: 8, distance: 2},
+			wantErr: true,
+		},
+		{name: "Invalid slot number",
+			carpark:      &Carpark{Map: values().map0, maxSlot: 10, entrances: map[string]*accessPoint{"A": {position: 1, distance: map[int]int{}}}},
+			args:         args{name: "A", slotNo: 11, distance: 2},
+			wantErr:      true,
+			wantDistance: map[int]int{},
+		},
+		{name: "Negative distance",
+			carpark:      &Carpark{Map: values().map0, maxSlot: 10, entrances: map[string]*accessPoint{"A": {position: 1, distance: map[int]int{}}}},
+			args:         args{name: "A", slotNo: 8, distance: -1},
+			wantErr:      true,
+			wantDistance: map[int]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.carpark.setDistance(tt.args.name, tt.args.slotNo, tt.args.distance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.setDistance() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if point, ok := tt.carpark.accessPoint(tt.args.name); ok && !reflect.DeepEqual(point.distance, tt.wantDistance) {
+				t.Errorf("distance = %v, want %v", point.distance, tt.wantDistance)
+			}
+		})
+	}
+}
+
+func TestCarpark_addEntrance(t *testing.T) {
+	tests := []struct {
+		name          string
+		carpark       *Carpark
+		gate          string
+		slotNo        int
+		wantErr       bool
+		wantEntrances map[string]*accessPoint
+	}{
+		{name: "New entrance",
+			carpark:       &Carpark{Map: values().map0, maxSlot: 10, entrances: map[string]*accessPoint{}},
+			gate:          "B",
+			slotNo:        10,
+			wantErr:       false,
+			wantEntrances: map[string]*accessPoint{"B": {position: 10, distance: map[int]int{}}},
+		},
+		{name: "Name of an exit",
+			carpark:       &Carpark{Map: values().map0, maxSlot: 10, entrances: map[string]*accessPoint{}, exits: map[string]*accessPoint{"B": {position: 1}}},
+			gate:          "B",
+			slotNo:        10,
+			wantErr:       true,
+			wantEntrances: map[string]*accessPoint{},
+		},
+		{name: "Invalid slot number",
+			carpark:       &Carpark{Map: values().map0, maxSlot: 10, entrances: map[string]*accessPoint{}},
+			gate:          "B",
+			slotNo:        11,
+			wantErr:       true,
+			wantEntrances: map[string]*accessPoint{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.carpark.addEntrance(tt.gate, tt.slotNo); (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.addEntrance() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tt.carpark.entrances, tt.wantEntrances) {
+				t.Errorf("entrances = %v, want %v", tt.carpark.entrances, tt.wantEntrances)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+)
+
+//AllocationStrategy chooses the slot allocated to a car among the empty slots which fit it
+type AllocationStrategy interface {
+	Choose(carpark *Carpark, candidates []int) int
+}
+
+//nearestStrategy allocates the slot nearest to the entry, which is the lowest numbered slot
+type nearestStrategy struct{}
+
+//Choose returns the lowest numbered candidate slot
+func (strategy nearestStrategy) Choose(carpark *Carpark, candidates []int) int {
+	return candidates[0]
+}
+
+//exitStrategy allocates the slot nearest to a named exit
+type exitStrategy struct {
+	exit string //Name of the exit
+}
+
+//Choose returns the candidate slot nearest to the exit, preferring the lowest numbered slot on a tie
+func (strategy exitStrategy) Choose(carpark *Carpark, candidates []int) int {
+	return nearestTo(carpark.exits[strategy.exit], candidates)
+}
+
+//fillByFloorStrategy allocates slots on the fullest floor first, so that cars are gathered on as few floors as possible
+type fillByFloorStrategy struct{}
+
+//Choose returns the lowest numbered candidate slot on the floor with the most parked cars
+func (strategy fillByFloorStrategy) Choose(carpark *Carpark, candidates []int) int {
+	parked := make(map[int]int)
+	for slotNo := range carpark.Map {
+		floor, _ := carpark.floorOf(slotNo)
+		parked[floor]++
+	}
+	chosen := candidates[0]
+	chosenFloor, _ := carpark.floorOf(chosen)
+	for _, slotNo := range candidates[1:] {
+		floor, _ := carpark.floorOf(slotNo)
+		if parked[floor] > parked[chosenFloor] {
+			chosen, chosenFloor = slotNo, floor
+		}
+	}
+	return chosen
+}
+
+//spreadStrategy allocates the least used slot, spreading wear evenly across the carpark
+type spreadStrategy struct{}
+
+//Choose returns the candidate slot allocated the fewest times, preferring the lowest numbered slot on a tie
+func (strategy spreadStrategy) Choose(carpark *Carpark, candidates []int) int {
+	chosen := candidates[0]
+	for _, slotNo := range candidates[1:] {
+		if carpark.slotUses[slotNo] < carpark.slotUses[chosen] {
+			chosen = slotNo
+		}
+	}
+	return chosen
+}
+
+//randomStrategy allocates a random slot, drawn from a seeded source so that allocations can be reproduced
+type randomStrategy struct {
+	seed   int64           //Seed of the source of random numbers
+	source *countingSource //Seeded source, counting the numbers drawn
+	rand   *rand.Rand      //Random numbers drawn from the source
+}
+
+//countingSource is a seeded source of random numbers which counts the numbers drawn, so that a sequence can be
+//resumed or rewound
+type countingSource struct {
+	rand.Source
+	seed  int64 //Seed of the source
+	draws int64 //Numbers drawn from the source
+}
+
+//Int63 draws the next number from the source
+func (source *countingSource) Int63() int64 {
+	source.draws++
+	return source.Source.Int63()
+}
+
+//Rewind or advance the source until the given number of numbers have been drawn
+func (source *countingSource) rewind(draws int64) {
+	if draws < source.draws {
+		source.Seed(source.seed)
+		source.draws = 0
+	}
+	for source.draws < draws {
+		source.Int63()
+	}
+}
+
+//newRandomStrategy creates a random strategy drawing from a source with the given seed
+func newRandomStrategy(seed int64) randomStrategy {
+	source := &countingSource{Source: rand.NewSource(seed), seed: seed}
+	return randomStrategy{seed: seed, source: source, rand: rand.New(source)}
+}
+
+//Choose returns a random candidate slot
+func (strategy randomStrategy) Choose(carpark *Carpark, candidates []int) int {
+	return candidates[strategy.rand.Intn(len(candidates))]
+}
+
+//newAllocationStrategy creates a built-in allocation strategy, where the "exit" strategy takes the name of
+//an exit and the "random" strategy takes an optional seed
+func (carpark *Carpark) newAllocationStrategy(name string, args ...string) (AllocationStrategy, error) {
+	switch {
+	case name == "nearest" && len(args) == 0:
+		return nearestStrategy{}, nil
+	case name == "exit" && len(args) == 1:
+		if _, ok := carpark.exits[args[0]]; !ok {
+			return nil, fmt.Errorf("Unknown exit %v", args[0])
+		}
+		return exitStrategy{exit: args[0]}, nil
+	case name == "fill_by_floor" && len(args) == 0:
+		return fillByFloorStrategy{}, nil
+	case name == "spread" && len(args) == 0:
+		return spreadStrategy{}, nil
+	case name == "random" && len(args) <= 1:
+		seed := carpark.now().UnixNano()
+		if len(args) == 1 {
+			var err error
+			seed, err = strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return nil, errors.New("Invalid random seed")
+			}
+		}
+		return newRandomStrategy(seed), nil
+	}
+	return nil, fmt.Errorf("Unknown allocation strategy %v", name)
+}
+
+//Allocate an empty slot which fits a car, among the slots most suitable by their attributes, nearest to the
+//entrance the car came through or else as chosen by the allocation strategy
+func (carpark *Carpark) allocate(car *Car) (int, bool) {
+	//Take the nearest slot to the main entry point straight from the heaps of empty slots
+	if _, ok := carpark.strategy.(nearestStrategy); car.gate == "" && len(carpark.attributes) == 0 &&
+		car.required|car.preferred == 0 && (ok || carpark.strategy == nil) {
+		return carpark.nextSlot(car.class)
+	}
+	candidates := carpark.suitableSlots(car, carpark.freeSlots(car.class))
+	if len(candidates) == 0 {
+		return 0, false
+	}
+	var slotNo int
+	switch {
+	case car.gate != "":
+		slotNo = nearestTo(carpark.entrances[car.gate], candidates)
+	case carpark.strategy == nil:
+		slotNo = candidates[0]
+	default:
+		slotNo = carpark.strategy.Choose(carpark, candidates)
+	}
+	carpark.claimSlot(slotNo)
+	return slotNo, true
+}
+
+//Retrieve the empty slots in service and not reserved which fit a vehicle of the given class, in ascending order
+func (carpark *Carpark) freeSlots(class VehicleClass) []int {
+	var slots []int
+	for slotNo := 1; slotNo <= carpark.maxSlot; slotNo++ {
+		_, parked := carpark.Map[slotNo]
+		if _, reserved := carpark.reserved[slotNo]; parked || reserved || carpark.closed[slotNo] {
+			continue
+		}
+		if class.fits(carpark.slotClass[slotNo]) {
+			slots = append(slots, slotNo)
+		}
+	}
+	return slots
+}
+
+//Take an empty slot out of the heaps of empty slots, keeping slots passed over in their heaps
+func (carpark *Carpark) claimSlot(slotNo int) {
+	if slotNo <= carpark.highestSlot {
+		carpark.emptySlot[carpark.slotClass[slotNo]].Remove(slotNo)
+		return
+	}
+	for carpark.highestSlot++; carpark.highestSlot < slotNo; carpark.highestSlot++ {
+		if !carpark.closed[carpark.highestSlot] {
+			carpark.emptySlot[carpark.slotClass[carpark.highestSlot]].Push(carpark.highestSlot)
+		}
+	}
+}
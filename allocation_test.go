@@ -0,0 +1,168 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestAllocationStrategy_Choose(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategy   AllocationStrategy
+		carpark    *Carpark
+		candidates []int
+		want       int
+	}{
+		{name: "Nearest to entry",
+			strategy:   nearestStrategy{},
+			carpark:    &Carpark{},
+			candidates: []int{3, 5, 7},
+			want:       3,
+		},
+		{name: "Nearest to exit",
+			strategy:   exitStrategy{exit: "B"},
+			carpark:    &Carpark{exits: map[string]*accessPoint{"B": {position: 6}}},
+			candidates: []int{2, 5, 8},
+			want:       5,
+		},
+		{name: "Nearest to exit on a tie",
+			strategy:   exitStrategy{exit: "B"},
+			carpark:    &Carpark{exits: map[string]*accessPoint{"B": {position: 6}}},
+			candidates: []int{4, 8},
+			want:       4,
+		},
+		{name: "Fill the fullest floor",
+			strategy:   fillByFloorStrategy{},
+			carpark:    &Carpark{Map: map[int]*Car{4: {slot: 4}, 5: {slot: 5}, 1: {slot: 1}}, floors: []int{3, 3}},
+			candidates: []int{2, 3, 6},
+			want:       6,
+		},
+		{name: "Fill the lower floor on a tie",
+			strategy:   fillByFloorStrategy{},
+			carpark:    &Carpark{Map: map[int]*Car{4: {slot: 4}, 1: {slot: 1}}, floors: []int{3, 3}},
+			candidates: []int{2, 3, 5, 6},
+			want:       2,
+		},
+		{name: "Spread to the least used slot",
+			strategy:   spreadStrategy{},
+			carpark:    &Carpark{slotUses: map[int]int{1: 3, 2: 1, 3: 1}},
+			candidates: []int{1, 2, 3},
+			want:       2,
+		},
+		{name: "Spread to a slot never used",
+			strategy:   spreadStrategy{},
+			carpark:    &Carpark{slotUses: map[int]int{1: 3, 2: 1}},
+			candidates: []int{1, 2, 3},
+			want:       3,
+		},
+		{name: "Random with a seed",
+			strategy:   randomStrategy{rand: rand.New(rand.NewSource(1))},
+			carpark:    &Carpark{},
+			candidates: []int{1, 2, 3, 4, 5},
+			want:       2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.strategy.Choose(tt.carpark, tt.candidates); got != tt.want {
+				t.Errorf("AllocationStrategy.Choose() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCarpark_allocate(t *testing.T) {
+	tests := []struct {
+		name        string
+		carpark     *Carpark
+		car         *Car
+		want        int
+		wantOk      bool
+		wantCarpark *Carpark
+	}{
+		{name: "Nearest slot from heap",
+			carpark:     indexed(&Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10, strategy: nearestStrategy{}}),
+			car:         values().car0,
+			want:        1,
+			wantOk:      true,
+			wantCarpark: indexed(&Carpark{Map: values().map2, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+		},
+		{name: "Least used slot beyond highest slot",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 5, strategy: spreadStrategy{}, slotUses: map[int]int{1: 1, 2: 1, 3: 1}}),
+			car:         values().car0,
+			want:        4,
+			wantOk:      true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: emptySlots(2, 3), highestSlot: 4, maxSlot: 5}),
+		},
+		{name: "Slot nearest to exit from heap",
+			carpark:     indexed(&Carpark{Map: map[int]*Car{1: values().car1, 4: {slot: 4}}, emptySlot: emptySlots(2, 3), highestSlot: 4, maxSlot: 4, strategy: exitStrategy{exit: "B"}, exits: map[string]*accessPoint{"B": {position: 3}}}),
+			car:         values().car0,
+			want:        3,
+			wantOk:      true,
+			wantCarpark: indexed(&Carpark{Map: map[int]*Car{1: values().car1, 4: {slot: 4}}, emptySlot: emptySlots(2), highestSlot: 4, maxSlot: 4}),
+		},
+		{name: "Closed and unfit slots passed over",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 5, strategy: exitStrategy{exit: "B"}, exits: map[string]*accessPoint{"B": {position: 5}}, closed: map[int]bool{2: true}, slotClass: map[int]VehicleClass{3: motorcycleClass, 5: motorcycleClass}}),
+			car:         values().car0,
+			want:        4,
+			wantOk:      true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: classEmptySlots(motorcycleClass, 3), highestSlot: 4, maxSlot: 5, closed: map[int]bool{2: true}, slotClass: map[int]VehicleClass{3: motorcycleClass, 5: motorcycleClass}}),
+		},
+		{name: "Slot nearest to entrance",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 5, entrances: map[string]*accessPoint{"B": {position: 5, distance: map[int]int{2: 0}}}}),
+			car:         &Car{gate: "B"},
+			want:        2,
+			wantOk:      true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 5}),
+		},
+		{name: "No slot fits",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 2, strategy: spreadStrategy{}, slotClass: map[int]VehicleClass{2: motorcycleClass}}),
+			car:         &Car{class: vanClass},
+			want:        0,
+			wantOk:      false,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 2, slotClass: map[int]VehicleClass{2: motorcycleClass}}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.carpark.allocate(tt.car)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Carpark.allocate() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+			compareCarpark(t, tt.carpark, tt.wantCarpark)
+		})
+	}
+}
+
+func TestCarpark_newAllocationStrategy(t *testing.T) {
+	carpark := &Carpark{exits: map[string]*accessPoint{"B": {position: 6}}}
+	tests := []struct {
+		name    string
+		args    []string
+		want    AllocationStrategy
+		wantErr bool
+	}{
+		{name: "Nearest to entry", args: []string{"nearest"}, want: nearestStrategy{}},
+		{name: "Nearest to exit", args: []string{"exit", "B"}, want: exitStrategy{exit: "B"}},
+		{name: "Unknown exit", args: []string{"exit", "C"}, wantErr: true},
+		{name: "Fill by floor", args: []string{"fill_by_floor"}, want: fillByFloorStrategy{}},
+		{name: "Spread", args: []string{"spread"}, want: spreadStrategy{}},
+		{name: "Random with a seed", args: []string{"random", "1"}, want: newRandomStrategy(1)},
+		{name: "Invalid random seed", args: []string{"random", "one"}, wantErr: true},
+		{name: "Unexpected argument", args: []string{"spread", "1"}, wantErr: true},
+		{name: "Unknown strategy", args: []string{"farthest"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := carpark.newAllocationStrategy(tt.args[0], tt.args[1:]...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.newAllocationStrategy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Carpark.newAllocationStrategy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//SlotAttributes represents a set of designations of a slot, such as being accessible or having an EV charger
+type SlotAttributes uint8
+
+//Slot attributes, each of which is a single bit of SlotAttributes
+const (
+	accessibleAttribute SlotAttributes = 1 << iota
+	evAttribute
+	vipAttribute
+	familyAttribute
+	numAttributes = iota //Number of slot attributes
+)
+
+var attributeNames = [numAttributes]string{"accessible", "ev", "vip", "family"}
+
+//String returns the names of the attributes in the set, separated by commas
+func (attributes SlotAttributes) String() string {
+	var names []string
+	for i, name := range attributeNames {
+		if attributes&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+//parseSlotAttributes converts a list of attribute names separated by commas into SlotAttributes
+func parseSlotAttributes(list string) (SlotAttributes, error) {
+	var attributes SlotAttributes
+	for _, name := range strings.Split(list, ",") {
+		found := false
+		for i, attributeName := range attributeNames {
+			if attributeName == name {
+				attributes |= 1 << i
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("Unknown slot attribute %v", name)
+		}
+	}
+	return attributes, nil
+}
+
+//Add attributes to a slot, or remove them from the slot when set is false
+func (carpark *Carpark) setSlotAttributes(slotNo int, attributes SlotAttributes, set bool) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	if slotNo < 1 || slotNo > carpark.maxSlot {
+		return errors.New("Invalid slot number")
+	}
+	if set {
+		carpark.attributes[slotNo] |= attributes
+	} else {
+		carpark.attributes[slotNo] &^= attributes
+	}
+	if carpark.attributes[slotNo] == 0 {
+		delete(carpark.attributes, slotNo)
+	}
+	return nil
+}
+
+//Set whether slots with the given attributes are given to cars which do not ask for them as a last resort, or never
+func (carpark *Carpark) setAttributeRule(attributes SlotAttributes, rule string) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	switch rule {
+	case "last_resort":
+		carpark.exclusive &^= attributes
+	case "never":
+		carpark.exclusive |= attributes
+	default:
+		return fmt.Errorf("Unknown slot attribute rule %v", rule)
+	}
+	return nil
+}
+
+//Filter the candidate slots for a car down to the most suitable slots by their attributes. Slots lacking an
+//attribute required by the car, or having an attribute reserved for cars asking for it, are never suitable.
+//Slots with attributes the car does not ask for are only suitable as a last resort, and slots with all the
+//attributes preferred by the car are more suitable than slots without.
+func (carpark *Carpark) suitableSlots(car *Car, candidates []int) []int {
+	var tiers [4][]int
+	asked := car.required | car.preferred
+	for _, slotNo := range candidates {
+		attributes := carpark.attributes[slotNo]
+		unasked := attributes &^ asked
+		if attributes&car.required != car.required || unasked&carpark.exclusive != 0 {
+			continue
+		}
+		tier := 0
+		if unasked != 0 {
+			tier += 2
+		}
+		if attributes&car.preferred != car.preferred {
+			tier++
+		}
+		tiers[tier] = append(tiers[tier], slotNo)
+	}
+	for _, slots := range tiers {
+		if len(slots) > 0 {
+			return slots
+		}
+	}
+	return nil
+}
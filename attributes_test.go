@@ -0,0 +1,135 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseSlotAttributes(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    SlotAttributes
+		wantErr bool
+	}{
+		{name: "Single attribute", list: "ev", want: evAttribute},
+		{name: "Several attributes", list: "family,accessible", want: accessibleAttribute | familyAttribute},
+		{name: "Unknown attribute", list: "ev,valet", want: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSlotAttributes(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSlotAttributes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseSlotAttributes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlotAttributes_String(t *testing.T) {
+	if got, want := (vipAttribute | accessibleAttribute).String(), "accessible,vip"; got != want {
+		t.Errorf("SlotAttributes.String() = %v, want %v", got, want)
+	}
+}
+
+func TestCarpark_suitableSlots(t *testing.T) {
+	attributes := map[int]SlotAttributes{1: accessibleAttribute, 2: evAttribute, 3: evAttribute | vipAttribute, 4: vipAttribute}
+	tests := []struct {
+		name       string
+		carpark    *Carpark
+		car        *Car
+		candidates []int
+		want       []int
+	}{
+		{name: "Plain slots first",
+			carpark:    &Carpark{attributes: attributes},
+			car:        &Car{},
+			candidates: []int{1, 2, 3, 4, 5, 6},
+			want:       []int{5, 6},
+		},
+		{name: "Slots with attributes as a last resort",
+			carpark:    &Carpark{attributes: attributes},
+			car:        &Car{},
+			candidates: []int{1, 2, 3},
+			want:       []int{1, 2, 3},
+		},
+		{name: "Slots with attributes never",
+			carpark:    &Carpark{attributes: attributes, exclusive: accessibleAttribute | vipAttribute},
+			car:        &Car{},
+			candidates: []int{1, 2, 3, 4},
+			want:       []int{2},
+		},
+		{name: "Required attribute",
+			carpark:    &Carpark{attributes: attributes},
+			car:        &Car{required: evAttribute},
+			candidates: []int{1, 3, 4, 5},
+			want:       []int{3},
+		},
+		{name: "Required attribute unavailable",
+			carpark:    &Carpark{attributes: attributes},
+			car:        &Car{required: familyAttribute},
+			candidates: []int{1, 2, 3, 4, 5},
+			want:       nil,
+		},
+		{name: "Preferred attribute",
+			carpark:    &Carpark{attributes: attributes},
+			car:        &Car{preferred: evAttribute},
+			candidates: []int{1, 2, 3, 5},
+			want:       []int{2},
+		},
+		{name: "Preferred attribute unavailable",
+			carpark:    &Carpark{attributes: attributes},
+			car:        &Car{preferred: evAttribute},
+			candidates: []int{1, 3, 5},
+			want:       []int{5},
+		},
+		{name: "Preferred attribute only with other attributes",
+			carpark:    &Carpark{attributes: attributes},
+			car:        &Car{preferred: evAttribute},
+			candidates: []int{1, 3, 4},
+			want:       []int{3},
+		},
+		{name: "Asked attributes of a slot reserved for them",
+			carpark:    &Carpark{attributes: attributes, exclusive: vipAttribute},
+			car:        &Car{required: evAttribute, preferred: vipAttribute},
+			candidates: []int{2, 3},
+			want:       []int{3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.carpark.suitableSlots(tt.car, tt.candidates); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Carpark.suitableSlots() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCarpark_setSlotAttributes(t *testing.T) {
+	carpark := &Carpark{Map: values().map0, maxSlot: 4, attributes: map[int]SlotAttributes{}}
+	steps := []struct {
+		slotNo         int
+		attributes     SlotAttributes
+		set            bool
+		wantErr        bool
+		wantAttributes map[int]SlotAttributes
+	}{
+		{slotNo: 2, attributes: evAttribute | vipAttribute, set: true, wantAttributes: map[int]SlotAttributes{2: evAttribute | vipAttribute}},
+		{slotNo: 2, attributes: vipAttribute, set: false, wantAttributes: map[int]SlotAttributes{2: evAttribute}},
+		{slotNo: 5, attributes: vipAttribute, set: true, wantErr: true, wantAttributes: map[int]SlotAttributes{2: evAttribute}},
+		{slotNo: 2, attributes: evAttribute, set: false, wantAttributes: map[int]SlotAttributes{}},
+	}
+	for _, step := range steps {
+		err := carpark.setSlotAttributes(step.slotNo, step.attributes, step.set)
+		if (err != nil) != step.wantErr {
+			t.Errorf("Carpark.setSlotAttributes() error = %v, wantErr %v", err, step.wantErr)
+		}
+		if !reflect.DeepEqual(carpark.attributes, step.wantAttributes) {
+			t.Errorf("attributes = %v, want %v", carpark.attributes, step.wantAttributes)
+		}
+	}
+}
@@ -1,8 +1,67 @@
 package main
 
+import (
+	"fmt"
+	"time"
+)
+
 // Car represents the properties of a car
 type Car struct {
-	slot         int    //Slot number in which the car is parked
-	registration string //Registration number of car
-	colour       string //Colour of car
+	slot         int            //Slot number in which the car is parked
+	registration string         //Registration number of car
+	colour       string         //Colour of car
+	class        VehicleClass   //Class of vehicle, which determines the slots it fits in
+	entry        time.Time      //Time at which the car was parked
+	exit         time.Time      //Time at which the car left, which is zero while the car is parked
+	ticket       string         //ID of the ticket issued to the car when it was parked
+	gate         string         //Entrance through which the car entered, which is empty for the main entry point
+	required     SlotAttributes //Attributes which the slot of the car must have
+	preferred    SlotAttributes //Attributes which the slot of the car should have if possible
+	chargeStart  time.Time      //Time at which the current charging session started, which is zero while not charging
+	chargeTime   time.Duration  //Total time of the completed charging sessions of the car
+	energy       float64        //Total energy delivered to the car in kWh
+	chargeCost   int            //Total cost of the energy delivered to the car in cents
+}
+
+//duration returns how long the car has been parked until now, or until it left
+func (car *Car) duration(now time.Time) time.Duration {
+	if !car.exit.IsZero() {
+		return car.exit.Sub(car.entry)
+	}
+	return now.Sub(car.entry)
+}
+
+//VehicleClass represents the size of a vehicle, or the size of vehicle a slot is built for
+type VehicleClass int
+
+//Vehicle and slot classes, where the zero value is the default class of vehicles and slots
+const (
+	carClass VehicleClass = iota
+	motorcycleClass
+	vanClass
+	busClass
+	numClasses //Number of vehicle classes
+)
+
+var classNames = [numClasses]string{"car", "motorcycle", "van", "bus"}
+var classSizes = [numClasses]int{2, 1, 3, 4}
+
+//String returns the name of the vehicle class
+func (class VehicleClass) String() string {
+	return classNames[class]
+}
+
+//fits verifies whether a vehicle of this class fits in a slot of the given class
+func (class VehicleClass) fits(slotClass VehicleClass) bool {
+	return classSizes[class] <= classSizes[slotClass]
+}
+
+//parseVehicleClass converts the name of a vehicle class into a VehicleClass
+func parseVehicleClass(name string) (VehicleClass, error) {
+	for class, className := range classNames {
+		if className == name {
+			return VehicleClass(class), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown vehicle class %v", name)
 }
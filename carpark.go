@@ -1,29 +1,99 @@
 package main
 
 import (
-	"container/heap"
 	"errors"
+	"fmt"
 	"minheap"
+	"strconv"
+	"strings"
+	"time"
 )
 
+//maxParkingLotSize is the largest number of slots of a carpark
+const maxParkingLotSize = 1000000
+
+//errFull is the error returned when no slot is available for a car
+var errFull = errors.New("Sorry, parking lot is full")
+
 //Carpark represents the carpark map, empty slots, and maximum number of slots filled
 type Carpark struct {
-	Map         map[int]*Car          //Properties of each car parked in the carpark
-	emptySlot   minheap.PriorityQueue //Heap containing sorted empty slots in ascending order
-	highestSlot int                   //Highest number of slots filled throughout carpark operation
-	maxSlot     int                   //Maximum number of slots available
+	Map          map[int]*Car                   //Properties of each car parked in the carpark
+	emptySlot    [numClasses]*minheap.Heap[int] //Heaps containing sorted empty slots of each slot class in ascending order
+	highestSlot  int                            //Highest number of slots filled throughout carpark operation
+	maxSlot      int                            //Maximum number of slots available
+	floors       []int                          //Number of slots on each floor, starting from the ground floor
+	slotClass    map[int]VehicleClass           //Class of each slot, where slots absent from the map are car slots
+	clock        Clock                          //Clock used to timestamp cars, which defaults to the system clock
+	tariff       *Tariff                        //Parking charges, which is nil when parking is free
+	tickets      map[string]int                 //Slot number of each parked car, keyed by the ID of its ticket
+	regs         map[string]int                 //Slot number of each parked car, keyed by its normalized registration number
+	colours      map[string]*slotSet            //Slot numbers of parked cars in ascending order, keyed by normalized colour
+	synonyms     map[string]string              //Colour which each alternative colour name is a synonym of
+	validator    RegistrationValidator          //Format check of registration numbers, which is nil to accept any
+	closed       map[int]bool                   //Slots which are out of service
+	strategy     AllocationStrategy             //Choice of slot allocated to each car, which is nil for the nearest slot to the entry
+	entrances    map[string]*accessPoint        //Named entrances of the carpark
+	exits        map[string]*accessPoint        //Named exits of the carpark
+	slotUses     map[int]int                    //Number of times each slot has been allocated
+	reservations map[string]*reservation        //Reservations of slots, keyed by the normalized registration number of the car
+	reserved     map[int]*reservation           //Reservation holding each reserved slot
+	expiries     *minheap.Heap[*reservation]    //Reservations in order of expiry
+	attributes   map[int]SlotAttributes         //Attributes of each slot, where slots absent from the map have none
+	exclusive    SlotAttributes                 //Attributes whose slots are never given to cars which do not ask for them
+	chargers     map[int]*charger               //EV chargers installed at each slot
+	waiting      *waitingList                   //Cars waiting for a slot, which is nil when cars are turned away from a full carpark
+	ticketNo     int                            //Number of tickets issued throughout carpark operation
+	journal      *journal                       //Log of parking and leaving, which is nil when mutations are not journaled
+	journaled    int                            //Sequence number of the last record written to the journal
+	events       []*event                       //Parking, moving and leaving of cars in order of time
+	tracking     bool                           //Whether changes to the carpark are tracked, so that they can be undone
+	changes      []change                       //Changes tracked since they were last taken
 }
 
-//Initialize carpark parameters
-func (carpark *Carpark) init(maxSlot int) error {
+//Initialize carpark parameters with the number of slots on each floor
+func (carpark *Carpark) init(floors ...int) error {
 	if err := carpark.initStatus(); err == nil {
 		return errors.New("Carpark already initialized")
 	}
-	carpark.Map = make(map[int]*Car)            //Setup a map of the carpark
-	carpark.emptySlot = minheap.PriorityQueue{} //Setup an empty heap of empty parking slots
-	heap.Init(&carpark.emptySlot)               //Initialize the heap of empty parking slots
-	carpark.maxSlot = maxSlot                   //Set the maximum number of slots
-	return nil
+	if len(floors) == 0 {
+		return errors.New("Invalid parking lot size")
+	}
+	maxSlot := 0
+	for _, slots := range floors {
+		if slots <= 0 || slots > maxParkingLotSize-maxSlot {
+			return errors.New("Invalid parking lot size")
+		}
+		maxSlot += slots
+	}
+	carpark.Map = make(map[int]*Car) //Setup a map of the carpark
+	for class := range carpark.emptySlot {
+		carpark.emptySlot[class] = newSlotHeap() //Setup an empty heap of empty parking slots
+	}
+	carpark.maxSlot = maxSlot                            //Set the maximum number of slots
+	carpark.floors = floors                              //Set the number of slots on each floor
+	carpark.slotClass = make(map[int]VehicleClass)       //Setup a map of slot classes
+	carpark.tickets = make(map[string]int)               //Setup a map of issued tickets
+	carpark.regs = make(map[string]int)                  //Setup a map of registration numbers
+	carpark.colours = make(map[string]*slotSet)          //Setup a map of car colours
+	carpark.synonyms = make(map[string]string)           //Setup a map of colour synonyms
+	carpark.closed = make(map[int]bool)                  //Setup a map of closed slots
+	carpark.entrances = make(map[string]*accessPoint)    //Setup a map of entrances
+	carpark.exits = make(map[string]*accessPoint)        //Setup a map of exits
+	carpark.attributes = make(map[int]SlotAttributes)    //Setup a map of slot attributes
+	carpark.chargers = make(map[int]*charger)            //Setup a map of chargers
+	carpark.slotUses = make(map[int]int)                 //Setup a map of slot usage
+	carpark.reservations = make(map[string]*reservation) //Setup a map of reservations
+	carpark.reserved = make(map[int]*reservation)        //Setup a map of reserved slots
+	carpark.expiries = newReservationHeap()              //Setup an empty heap of reservations
+	for colour, synonym := range defaultColourSynonyms {
+		carpark.synonyms[colour] = synonym
+	}
+	return carpark.record(journalRecord{Op: "init", Time: carpark.now(), Floors: floors})
+}
+
+//newSlotHeap returns an empty heap of slot numbers in ascending order
+func newSlotHeap() *minheap.Heap[int] {
+	return minheap.New(func(a, b int) bool { return a < b })
 }
 
 //Park a car in carpark
@@ -31,67 +101,396 @@ func (carpark *Carpark) insertCar(car *Car) (int, error) {
 	if err := carpark.initStatus(); err != nil {
 		return 0, err
 	}
-	var slotNo int
-	//Check whether all slots are occupied
-	if carpark.emptySlot.Len() == 0 {
-		if carpark.highestSlot == carpark.maxSlot { //Check whether all slots are occupied
-			return 0, errors.New("Sorry, parking lot is full")
+	//Check the format of the registration number
+	if carpark.validator != nil {
+		if err := carpark.validator.Validate(car.registration); err != nil {
+			return 0, err
+		}
+	}
+	//Check whether the car is already parked
+	if _, ok := carpark.regs[normalizeRegistration(car.registration)]; ok {
+		return 0, fmt.Errorf("Car %v is already parked", car.registration)
+	}
+	carpark.expireReservations()
+	before, booking := carpark.frontier(), carpark.reservations[normalizeRegistration(car.registration)]
+	slotNo, err := carpark.reservedSlot(car)
+	if err != nil {
+		return 0, err
+	}
+	if slotNo == 0 {
+		//Check whether all slots are occupied, closed or reserved
+		if len(carpark.Map)+len(carpark.closed)+len(carpark.reserved) == carpark.maxSlot {
+			return 0, errFull
+		}
+		if _, ok := carpark.entrances[car.gate]; car.gate != "" && !ok {
+			return 0, fmt.Errorf("Unknown entrance %v", car.gate)
+		}
+		var ok bool
+		slotNo, ok = carpark.allocate(car)
+		if !ok {
+			//Leave the heaps and the highest slot filled as they were, so that a failed park changes nothing
+			carpark.restoreFrontier(carpark.passedSince(before, 0))
+		}
+		if !ok && car.required != 0 {
+			return 0, fmt.Errorf("%w for class %v with %v", errFull, car.class, car.required)
+		}
+		if !ok {
+			return 0, fmt.Errorf("%w for class %v", errFull, car.class)
 		}
-		//Get next available slot
-		slotNo = carpark.highestSlot + 1
-		carpark.highestSlot = slotNo
-	} else { //Get nearest empty slot which was previously occupied
-		item := heap.Pop(&carpark.emptySlot)
-		slotNo = item.(*minheap.Item).Value
 	}
-	//Park the car at the slotNo
+	carpark.park(car, slotNo)
+	carpark.track(&parking{car: car, slot: slotNo, entry: car.entry, frontier: carpark.passedSince(before, slotNo), booking: booking})
+	saved := newSnapshotCar(car)
+	return slotNo, carpark.record(journalRecord{Op: "park", Time: car.entry, Car: &saved})
+}
+
+//Park a car at an allocated slot and issue a ticket to it
+func (carpark *Carpark) park(car *Car, slotNo int) {
 	car.slot = slotNo
+	car.entry = carpark.now()
 	carpark.Map[slotNo] = car
-	return slotNo, nil
+	carpark.slotUses[slotNo]++
+	carpark.regs[normalizeRegistration(car.registration)] = slotNo
+	carpark.indexColour(car)
+	//Issue a ticket to the car
+	carpark.ticketNo++
+	car.ticket = fmt.Sprintf("T%06d", carpark.ticketNo)
+	carpark.tickets[car.ticket] = slotNo
+	carpark.logEvent(parkEvent, car, car.entry)
+}
+
+//Take the nearest empty slot which fits a vehicle of the given class
+func (carpark *Carpark) nextSlot(class VehicleClass) (int, bool) {
+	//Get nearest empty slot which was previously occupied
+	nearest, nearestSlot := -1, 0
+	for slotClass := range carpark.emptySlot {
+		slotNo, ok := carpark.emptySlot[slotClass].Peek()
+		if !ok || !class.fits(VehicleClass(slotClass)) {
+			continue
+		}
+		if nearest < 0 || slotNo < nearestSlot {
+			nearest, nearestSlot = slotClass, slotNo
+		}
+	}
+	if nearest >= 0 {
+		carpark.emptySlot[nearest].Pop()
+		return nearestSlot, true
+	}
+	//Get next available slot, keeping slots of other classes passed over in their heaps
+	for carpark.highestSlot < carpark.maxSlot {
+		carpark.highestSlot++
+		slotNo := carpark.highestSlot
+		slotClass := carpark.slotClass[slotNo]
+		if carpark.closed[slotNo] {
+			continue
+		}
+		if class.fits(slotClass) {
+			return slotNo, true
+		}
+		carpark.emptySlot[slotClass].Push(slotNo)
+	}
+	return 0, false
 }
 
 //Remove car from carpark
-func (carpark *Carpark) removeCar(slotNo int) error {
+func (carpark *Carpark) removeCar(slotNo int) (*Car, error) {
 	if err := carpark.initStatus(); err != nil {
-		return err
+		return nil, err
 	}
-	if _, ok := carpark.Map[slotNo]; ok {
+	if car, ok := carpark.Map[slotNo]; ok {
+		departure := &departure{car: car, before: *car}
+		if point := carpark.chargers[slotNo]; point != nil && !car.chargeStart.IsZero() {
+			departure.charger, departure.chargerBefore = point, *point
+		}
 		//Remove car from carpark Map
 		delete(carpark.Map, slotNo)
-		//Add empty slot to the heap
-		heap.Push(&carpark.emptySlot, &minheap.Item{Value: slotNo})
-		return nil
+		delete(carpark.tickets, car.ticket)
+		delete(carpark.regs, normalizeRegistration(car.registration))
+		carpark.unindexColour(car)
+		car.exit = carpark.now()
+		carpark.logEvent(leaveEvent, car, car.exit)
+		//End a charging session in progress
+		if !car.chargeStart.IsZero() {
+			carpark.endCharge(car)
+		}
+		//Add empty slot to the heap of its slot class
+		carpark.emptySlot[carpark.slotClass[slotNo]].Push(slotNo)
+		departure.exit = car.exit
+		carpark.track(departure)
+		return car, carpark.record(journalRecord{Op: "leave", Time: car.exit, Slot: slotNo, Registration: car.registration})
 	}
-	return errors.New("Car non-existent in carpark")
+	return nil, errors.New("Car non-existent in carpark")
 }
 
-//Given a car colour, retrieve the car slot and registration numbers
-func (carpark *Carpark) getCarsWithColour(colour string) ([]int, []string, error) {
-	var slots []int
-	var registrations []string
-	for i := 1; i <= carpark.highestSlot; i++ {
-		car, ok := carpark.Map[i]
-		if ok && car.colour == colour {
-			slots = append(slots, car.slot)
-			registrations = append(registrations, car.registration)
+//Add slots to the top floor of the carpark
+func (carpark *Carpark) expand(slots int) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	if slots <= 0 || slots > maxParkingLotSize-carpark.maxSlot {
+		return errors.New("Invalid number of slots")
+	}
+	carpark.floors[len(carpark.floors)-1] += slots
+	carpark.maxSlot += slots
+	return carpark.record(journalRecord{Op: "expand", Time: carpark.now(), Slots: slots})
+}
+
+//Remove slots from the top floors of the carpark, provided the removed slots are empty
+func (carpark *Carpark) shrink(slots int) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	carpark.expireReservations()
+	if slots <= 0 || slots >= carpark.maxSlot {
+		return errors.New("Invalid number of slots")
+	}
+	maxSlot := carpark.maxSlot - slots
+	//Check whether any removed slot is occupied or reserved
+	var occupied []string
+	for slotNo := maxSlot + 1; slotNo <= carpark.highestSlot; slotNo++ {
+		_, parked := carpark.Map[slotNo]
+		if _, reserved := carpark.reserved[slotNo]; parked || reserved {
+			occupied = append(occupied, carpark.slotLabel(slotNo))
 		}
 	}
-	if slots == nil {
+	if occupied != nil {
+		return fmt.Errorf("Cannot shrink parking lot, occupied slots: %v", strings.Join(occupied, ", "))
+	}
+	//Purge removed slots from the heaps of empty slots
+	for class := range carpark.emptySlot {
+		for _, slotNo := range carpark.emptySlot[class].Values() {
+			if slotNo > maxSlot {
+				carpark.emptySlot[class].Remove(slotNo)
+			}
+		}
+	}
+	for slotNo := range carpark.slotClass {
+		if slotNo > maxSlot {
+			delete(carpark.slotClass, slotNo)
+		}
+	}
+	for slotNo := range carpark.closed {
+		if slotNo > maxSlot {
+			delete(carpark.closed, slotNo)
+		}
+	}
+	for slotNo := range carpark.slotUses {
+		if slotNo > maxSlot {
+			delete(carpark.slotUses, slotNo)
+		}
+	}
+	for slotNo := range carpark.attributes {
+		if slotNo > maxSlot {
+			delete(carpark.attributes, slotNo)
+		}
+	}
+	for slotNo := range carpark.chargers {
+		if slotNo > maxSlot {
+			delete(carpark.chargers, slotNo)
+		}
+	}
+	carpark.trimAccessPoints(maxSlot)
+	if carpark.highestSlot > maxSlot {
+		carpark.highestSlot = maxSlot
+	}
+	//Remove slots from the top floors, dropping floors left without slots
+	for remaining := slots; remaining > 0; {
+		top := len(carpark.floors) - 1
+		if carpark.floors[top] > remaining {
+			carpark.floors[top] -= remaining
+			break
+		}
+		remaining -= carpark.floors[top]
+		carpark.floors = carpark.floors[:top]
+	}
+	carpark.maxSlot = maxSlot
+	return carpark.record(journalRecord{Op: "shrink", Time: carpark.now(), Slots: slots})
+}
+
+//Remove the car holding a ticket from carpark
+func (carpark *Carpark) removeCarWithTicket(ticket string) (*Car, error) {
+	if err := carpark.initStatus(); err != nil {
+		return nil, err
+	}
+	slotNo, ok := carpark.tickets[ticket]
+	if !ok {
+		return nil, errors.New("Invalid ticket")
+	}
+	return carpark.removeCar(slotNo)
+}
+
+//Verify that a ticket was issued to the car parked in a slot
+func (carpark *Carpark) verifyTicket(slotNo int, ticket string) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	car, ok := carpark.Map[slotNo]
+	if !ok {
+		return errors.New("Car non-existent in carpark")
+	}
+	if car.ticket != ticket {
+		return errors.New("Ticket does not match the car in the slot")
+	}
+	return nil
+}
+
+//Set the class of vehicle an empty slot is built for
+func (carpark *Carpark) setSlotClass(slotNo int, class VehicleClass) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	carpark.expireReservations()
+	if slotNo < 1 || slotNo > carpark.maxSlot {
+		return errors.New("Invalid slot number")
+	}
+	if _, ok := carpark.Map[slotNo]; ok {
+		return errors.New("Slot is occupied")
+	}
+	if _, ok := carpark.reserved[slotNo]; ok {
+		return errors.New("Slot is reserved")
+	}
+	oldClass := carpark.slotClass[slotNo]
+	if class == carClass {
+		delete(carpark.slotClass, slotNo)
+	} else {
+		carpark.slotClass[slotNo] = class
+	}
+	//Move a previously occupied slot to the heap of its new slot class
+	if carpark.emptySlot[oldClass].Remove(slotNo) {
+		carpark.emptySlot[class].Push(slotNo)
+	}
+	return carpark.record(journalRecord{Op: "set_slot_class", Time: carpark.now(), Slot: slotNo, Class: class.String()})
+}
+
+//Close an empty slot, taking it out of service
+func (carpark *Carpark) closeSlot(slotNo int) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	carpark.expireReservations()
+	if slotNo < 1 || slotNo > carpark.maxSlot {
+		return errors.New("Invalid slot number")
+	}
+	if _, ok := carpark.Map[slotNo]; ok {
+		return errors.New("Slot is occupied")
+	}
+	if _, ok := carpark.reserved[slotNo]; ok {
+		return errors.New("Slot is reserved")
+	}
+	if carpark.closed[slotNo] {
+		return errors.New("Slot is already closed")
+	}
+	//Withdraw a previously occupied slot from the heap of empty slots
+	carpark.emptySlot[carpark.slotClass[slotNo]].Remove(slotNo)
+	carpark.closed[slotNo] = true
+	return carpark.record(journalRecord{Op: "close_slot", Time: carpark.now(), Slot: slotNo})
+}
+
+//Open a closed slot, putting it back into service
+func (carpark *Carpark) openSlot(slotNo int) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	if !carpark.closed[slotNo] {
+		return errors.New("Slot is not closed")
+	}
+	delete(carpark.closed, slotNo)
+	//Return a slot passed over while closed to the heap of empty slots
+	if slotNo <= carpark.highestSlot {
+		carpark.emptySlot[carpark.slotClass[slotNo]].Push(slotNo)
+	}
+	return carpark.record(journalRecord{Op: "open_slot", Time: carpark.now(), Slot: slotNo})
+}
+
+//Rebuild the indexes of parked cars by ticket, registration number and colour from the carpark map
+func (carpark *Carpark) reindex() {
+	carpark.tickets = make(map[string]int)
+	carpark.regs = make(map[string]int)
+	carpark.colours = make(map[string]*slotSet)
+	for slotNo, car := range carpark.Map {
+		carpark.tickets[car.ticket] = slotNo
+		carpark.regs[normalizeRegistration(car.registration)] = slotNo
+		carpark.indexColour(car)
+	}
+}
+
+//Given a car colour, retrieve the car slot and registration numbers
+func (carpark *Carpark) getCarsWithColour(colour string) ([]int, []string, error) {
+	set, ok := carpark.colours[carpark.normalizeColour(colour)]
+	if !ok {
 		return nil, nil, errors.New("Not found")
 	}
+	slots := make([]int, len(*set))
+	registrations := make([]string, len(*set))
+	for i, slotNo := range *set {
+		slots[i] = slotNo
+		registrations[i] = carpark.Map[slotNo].registration
+	}
 	return slots, registrations, nil
 }
 
+//Add a parked car to the index of car colours
+func (carpark *Carpark) indexColour(car *Car) {
+	colour := carpark.normalizeColour(car.colour)
+	set, ok := carpark.colours[colour]
+	if !ok {
+		set = &slotSet{}
+		carpark.colours[colour] = set
+	}
+	set.add(car.slot)
+}
+
+//Remove a car from the index of car colours
+func (carpark *Carpark) unindexColour(car *Car) {
+	colour := carpark.normalizeColour(car.colour)
+	set, ok := carpark.colours[colour]
+	if !ok {
+		return
+	}
+	set.remove(car.slot)
+	if len(*set) == 0 {
+		delete(carpark.colours, colour)
+	}
+}
+
 //Given a car registration number, retrieve the car slot number
 func (carpark *Carpark) getCarWithRegistrationNo(registration string) (int, error) {
-	for _, car := range carpark.Map {
-		if car.registration == registration {
-			return car.slot, nil
-		}
+	if slotNo, ok := carpark.regs[normalizeRegistration(registration)]; ok {
+		return slotNo, nil
 	}
 	return 0, errors.New("Not found")
 }
 
+//Given a car registration number, retrieve how long the car has been parked
+func (carpark *Carpark) getDurationWithRegistrationNo(registration string) (time.Duration, error) {
+	slotNo, err := carpark.getCarWithRegistrationNo(registration)
+	if err != nil {
+		return 0, err
+	}
+	return carpark.Map[slotNo].duration(carpark.now()), nil
+}
+
+//Compute the parking fee of a car, up to the time it left or up to now if it is still parked
+func (carpark *Carpark) fee(car *Car) (int, error) {
+	if carpark.tariff == nil {
+		return 0, errors.New("No tariff loaded")
+	}
+	exit := car.exit
+	if exit.IsZero() {
+		exit = carpark.now()
+	}
+	return carpark.tariff.rates(car.class).fee(car.entry, exit), nil
+}
+
+//Given a car registration number, retrieve the parking fee of its current stay
+func (carpark *Carpark) quote(registration string) (int, error) {
+	slotNo, err := carpark.getCarWithRegistrationNo(registration)
+	if err != nil {
+		return 0, err
+	}
+	return carpark.fee(carpark.Map[slotNo])
+}
+
 //Retrieve ordered sequence of cars parked in the carpark
 func (carpark *Carpark) getStatus() []*Car {
 	var cars []*Car
@@ -111,3 +510,54 @@ func (carpark *Carpark) initStatus() error {
 	}
 	return nil
 }
+
+//Locate the floor and the position within the floor of a slot.
+//Slots are numbered consecutively across floors, starting from the ground floor.
+func (carpark *Carpark) floorOf(slotNo int) (int, int) {
+	pos := slotNo
+	for i, slots := range carpark.floors {
+		if pos <= slots {
+			return i + 1, pos
+		}
+		pos -= slots
+	}
+	return len(carpark.floors), pos
+}
+
+//Given a slot number, retrieve the slot identifier shown to users.
+//Single floor carparks use plain slot numbers, while multi-level carparks use "floor-slot".
+func (carpark *Carpark) slotLabel(slotNo int) string {
+	if len(carpark.floors) <= 1 {
+		return strconv.Itoa(slotNo)
+	}
+	floor, pos := carpark.floorOf(slotNo)
+	return fmt.Sprintf("%v-%v", floor, pos)
+}
+
+//Given a slot identifier, either a plain slot number or "floor-slot", retrieve the slot number
+func (carpark *Carpark) parseSlot(id string) (int, error) {
+	parts := strings.Split(id, "-")
+	if len(parts) > 2 {
+		return 0, errors.New("Invalid slot number")
+	}
+	nums := make([]int, len(parts))
+	for i, part := range parts {
+		num, err := strconv.Atoi(part)
+		if err != nil || num <= 0 {
+			return 0, errors.New("Invalid slot number")
+		}
+		nums[i] = num
+	}
+	if len(nums) == 1 {
+		return nums[0], nil
+	}
+	floor, pos := nums[0], nums[1]
+	if floor > len(carpark.floors) || pos > carpark.floors[floor-1] {
+		return 0, errors.New("Invalid slot number")
+	}
+	slotNo := pos
+	for _, slots := range carpark.floors[:floor-1] {
+		slotNo += slots
+	}
+	return slotNo, nil
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"fmt"
 	"minheap"
 	"reflect"
+	"regexp"
 	"testing"
+	"time"
 )
 
 //variables act as a struct of all parameters used in testing
 type variables struct {
+	now          time.Time
 	car0         *Car
 	car1         *Car
 	car2         *Car
@@ -15,48 +19,98 @@ type variables struct {
 	map1         map[int]*Car
 	map2         map[int]*Car
 	mapAll       map[int]*Car
-	item1        *minheap.Item
-	item2        *minheap.Item
-	emptySlot0   minheap.PriorityQueue
-	emptySlot1   minheap.PriorityQueue
-	emptySlot2   minheap.PriorityQueue
-	emptySlotAll minheap.PriorityQueue
+	emptySlot0   [numClasses]*minheap.Heap[int]
+	emptySlot1   [numClasses]*minheap.Heap[int]
+	emptySlot2   [numClasses]*minheap.Heap[int]
+	emptySlotAll [numClasses]*minheap.Heap[int]
+}
+
+//emptySlots returns the heaps of empty slots of each slot class, with the given empty car slots
+func emptySlots(carSlots ...int) [numClasses]*minheap.Heap[int] {
+	return classEmptySlots(carClass, carSlots...)
+}
+
+//classEmptySlots returns the heaps of empty slots of each slot class, with the given empty slots of one class
+func classEmptySlots(class VehicleClass, slots ...int) [numClasses]*minheap.Heap[int] {
+	var emptySlot [numClasses]*minheap.Heap[int]
+	for i := range emptySlot {
+		emptySlot[i] = newSlotHeap()
+	}
+	for _, slotNo := range slots {
+		emptySlot[class].Push(slotNo)
+	}
+	return emptySlot
 }
 
 //values() acts a storage of default values and return a 'variables' struct containing default values
 func values() variables {
+	now := time.Date(2019, 1, 1, 8, 0, 0, 0, time.UTC)
 	defaultValues := variables{
+		now:        now,
 		car0:       &Car{registration: "KA-01-HH-2701", colour: "Blue"},
-		car1:       &Car{slot: 1, registration: "KA-01-HH-1234", colour: "White"},
-		car2:       &Car{slot: 2, registration: "KA-01-HH-7777", colour: "Red"},
+		car1:       &Car{slot: 1, registration: "KA-01-HH-1234", colour: "White", entry: now, ticket: "T000001"},
+		car2:       &Car{slot: 2, registration: "KA-01-HH-7777", colour: "Red", entry: now, ticket: "T000002"},
 		map0:       make(map[int]*Car),
-		item1:      &minheap.Item{Value: 1},
-		item2:      &minheap.Item{Value: 2},
-		emptySlot0: minheap.PriorityQueue{},
+		emptySlot0: emptySlots(),
 	}
 	defaultValues.map1 = map[int]*Car{1: defaultValues.car1}
 	defaultValues.map2 = map[int]*Car{2: defaultValues.car2}
 	defaultValues.mapAll = map[int]*Car{1: defaultValues.car1, 2: defaultValues.car2}
-	defaultValues.emptySlot1 = minheap.PriorityQueue{defaultValues.item1}
-	defaultValues.emptySlot2 = minheap.PriorityQueue{defaultValues.item2}
-	defaultValues.emptySlotAll = minheap.PriorityQueue{defaultValues.item1, defaultValues.item2}
+	defaultValues.emptySlot1 = emptySlots(1)
+	defaultValues.emptySlot2 = emptySlots(2)
+	defaultValues.emptySlotAll = emptySlots(1, 2)
 
 	return defaultValues
 }
 
+//indexed builds the indexes of a 'Carpark' struct from the cars in its map
+func indexed(carpark *Carpark) *Carpark {
+	carpark.tickets = make(map[string]int)
+	carpark.regs = make(map[string]int)
+	carpark.colours = make(map[string]*slotSet)
+	for slotNo, car := range carpark.Map {
+		carpark.tickets[car.ticket] = slotNo
+		carpark.regs[normalizeRegistration(car.registration)] = slotNo
+		carpark.indexColour(car)
+	}
+	return carpark
+}
+
+//equalEmptySlots compares the empty slots of each slot class in two sets of heaps
+func equalEmptySlots(emptySlot [numClasses]*minheap.Heap[int], wantEmptySlot [numClasses]*minheap.Heap[int]) bool {
+	for class := range emptySlot {
+		if emptySlot[class] == nil || wantEmptySlot[class] == nil {
+			if emptySlot[class] != wantEmptySlot[class] {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(emptySlot[class].Values(), wantEmptySlot[class].Values()) {
+			return false
+		}
+	}
+	return true
+}
+
 //Compare two 'Carpark' structs
 func compareCarpark(t *testing.T, carpark *Carpark, wantCarpark *Carpark) {
 	if !reflect.DeepEqual(carpark.Map, wantCarpark.Map) ||
-		!reflect.DeepEqual(carpark.emptySlot, wantCarpark.emptySlot) ||
+		!equalEmptySlots(carpark.emptySlot, wantCarpark.emptySlot) ||
 		carpark.highestSlot != wantCarpark.highestSlot ||
-		carpark.maxSlot != wantCarpark.maxSlot {
+		carpark.maxSlot != wantCarpark.maxSlot ||
+		!reflect.DeepEqual(carpark.floors, wantCarpark.floors) ||
+		!reflect.DeepEqual(carpark.slotClass, wantCarpark.slotClass) ||
+		!reflect.DeepEqual(carpark.tickets, wantCarpark.tickets) ||
+		!reflect.DeepEqual(carpark.regs, wantCarpark.regs) ||
+		!reflect.DeepEqual(carpark.colours, wantCarpark.colours) ||
+		!reflect.DeepEqual(carpark.closed, wantCarpark.closed) {
 		t.Errorf("gotCarpark = %v, wantCarpark = %v", carpark, wantCarpark)
 	}
 }
 
 func TestCarpark_init(t *testing.T) {
 	type args struct {
-		maxSlot int
+		floors []int
 	}
 	tests := []struct {
 		name        string
@@ -67,20 +121,38 @@ func TestCarpark_init(t *testing.T) {
 	}{
 		{name: "Carpark not initialized",
 			carpark:     &Carpark{},
-			args:        args{maxSlot: 12},
+			args:        args{floors: []int{12}},
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 0, maxSlot: 12, floors: []int{12}, slotClass: map[int]VehicleClass{}, closed: map[int]bool{}}),
+		},
+		{name: "Multi-level carpark",
+			carpark:     &Carpark{},
+			args:        args{floors: []int{40, 40, 30}},
 			wantErr:     false,
-			wantCarpark: &Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 0, maxSlot: 12},
+			wantCarpark: indexed(&Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 0, maxSlot: 110, floors: []int{40, 40, 30}, slotClass: map[int]VehicleClass{}, closed: map[int]bool{}}),
+		},
+		{name: "Floor without slots",
+			carpark:     &Carpark{},
+			args:        args{floors: []int{40, 0}},
+			wantErr:     true,
+			wantCarpark: &Carpark{},
+		},
+		{name: "Too many slots",
+			carpark:     &Carpark{},
+			args:        args{floors: []int{maxParkingLotSize, 1}},
+			wantErr:     true,
+			wantCarpark: &Carpark{},
 		},
 		{name: "Carpark already initialized",
-			carpark:     &Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 8, maxSlot: 10},
-			args:        args{maxSlot: 12},
+			carpark:     indexed(&Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 8, maxSlot: 10}),
+			args:        args{floors: []int{12}},
 			wantErr:     true,
-			wantCarpark: &Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 8, maxSlot: 10},
+			wantCarpark: indexed(&Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 8, maxSlot: 10}),
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.carpark.init(tt.args.maxSlot)
+			err := tt.carpark.init(tt.args.floors...)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Carpark.init() error = %v, wantErr = %v", err, tt.wantErr)
 				return
@@ -110,30 +182,127 @@ func TestCarpark_insertCar(t *testing.T) {
 			wantCarpark: &Carpark{},
 		},
 		{name: "Insert car into new slot",
-			carpark:     &Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10},
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, ticketNo: 1}),
 			args:        args{car: values().car2},
 			want:        2,
 			wantErr:     false,
-			wantCarpark: &Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10},
+			wantCarpark: indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
 		},
 		{name: "Insert car into a previously occupied but now free slot",
-			carpark:     &Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10},
+			carpark:     indexed(&Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10}),
 			args:        args{car: values().car1},
 			want:        1,
 			wantErr:     false,
-			wantCarpark: &Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10},
+			wantCarpark: indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+		},
+		{name: "Insert van into the nearest van slot",
+			carpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, ticketNo: 1,
+				slotClass: map[int]VehicleClass{4: vanClass}}),
+			args:    args{car: &Car{registration: "KA-01-HH-2701", colour: "Blue", class: vanClass}},
+			want:    4,
+			wantErr: false,
+			wantCarpark: indexed(&Carpark{Map: map[int]*Car{1: values().car1, 4: {slot: 4, registration: "KA-01-HH-2701", colour: "Blue", class: vanClass, entry: values().now, ticket: "T000002"}},
+				emptySlot: emptySlots(2, 3), highestSlot: 4, maxSlot: 10,
+				slotClass: map[int]VehicleClass{4: vanClass}}),
+		},
+		{name: "Insert motorcycle into the nearest larger slot",
+			carpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, ticketNo: 1,
+				slotClass: map[int]VehicleClass{2: busClass}}),
+			args:    args{car: &Car{registration: "KA-01-HH-2701", colour: "Blue", class: motorcycleClass}},
+			want:    2,
+			wantErr: false,
+			wantCarpark: indexed(&Carpark{Map: map[int]*Car{1: values().car1, 2: {slot: 2, registration: "KA-01-HH-2701", colour: "Blue", class: motorcycleClass, entry: values().now, ticket: "T000002"}},
+				emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10,
+				slotClass: map[int]VehicleClass{2: busClass}}),
+		},
+		{name: "Insert bus without a bus slot",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 2}),
+			args:        args{car: &Car{registration: "KA-01-HH-2701", colour: "Blue", class: busClass}},
+			want:        0,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 2}),
+		},
+		{name: "Insert car with malformed registration number",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, validator: &regexValidator{format: "india", pattern: regexp.MustCompile(registrationFormats["india"])}}),
+			args:        args{car: &Car{registration: "MH-04-AY-", colour: "White"}},
+			want:        0,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+		},
+		{name: "Insert car skipping a closed slot",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, ticketNo: 1, closed: map[int]bool{2: true}}),
+			args:        args{car: &Car{registration: "KA-01-HH-2701", colour: "Blue"}},
+			want:        3,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: map[int]*Car{1: values().car1, 3: {slot: 3, registration: "KA-01-HH-2701", colour: "Blue", entry: values().now, ticket: "T000002"}}, emptySlot: values().emptySlot0, highestSlot: 3, maxSlot: 10, closed: map[int]bool{2: true}}),
+		},
+		{name: "Insert car with remaining slots closed",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 2, closed: map[int]bool{2: true}}),
+			args:        args{car: values().car0},
+			want:        0,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 2, closed: map[int]bool{2: true}}),
+		},
+		{name: "Insert car which is already parked",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, ticketNo: 1}),
+			args:        args{car: &Car{registration: "KA-01-HH-1234", colour: "White"}},
+			want:        0,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+		},
+		{name: "Insert car through an entrance",
+			carpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, ticketNo: 1,
+				entrances: map[string]*accessPoint{"B": {position: 10}}}),
+			args:        args{car: &Car{registration: "KA-01-HH-2701", colour: "Blue", gate: "B"}},
+			want:        10,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: map[int]*Car{1: values().car1, 10: {slot: 10, registration: "KA-01-HH-2701", colour: "Blue", entry: values().now, ticket: "T000002", gate: "B"}}, emptySlot: emptySlots(2, 3, 4, 5, 6, 7, 8, 9), highestSlot: 10, maxSlot: 10}),
+		},
+		{name: "Insert car through an unknown entrance",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, entrances: map[string]*accessPoint{}}),
+			args:        args{car: &Car{registration: "KA-01-HH-2701", colour: "Blue", gate: "C"}},
+			want:        0,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+		},
+		{name: "Insert car into its reserved slot",
+			carpark: reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 3, maxSlot: 3, ticketNo: 1}),
+				&reservation{registration: "KA-01-HH-2701", slot: 3, until: values().now.Add(time.Hour)},
+				&reservation{registration: "KA-01-HH-7777", slot: 2, until: values().now.Add(time.Hour)}),
+			args:        args{car: values().car0},
+			want:        3,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: map[int]*Car{1: values().car1, 3: {slot: 3, registration: "KA-01-HH-2701", colour: "Blue", entry: values().now, ticket: "T000002"}}, emptySlot: values().emptySlot0, highestSlot: 3, maxSlot: 3}),
+		},
+		{name: "Insert car into reserved slot which does not fit",
+			carpark: reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, slotClass: map[int]VehicleClass{2: motorcycleClass}}),
+				&reservation{registration: "KA-01-HH-2701", slot: 2, until: values().now.Add(time.Hour)}),
+			args:        args{car: values().car0},
+			want:        0,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, slotClass: map[int]VehicleClass{2: motorcycleClass}}),
+		},
+		{name: "Insert car into carpark full of reservations",
+			carpark: reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 2}),
+				&reservation{registration: "KA-01-HH-7777", slot: 2, until: values().now.Add(time.Hour)}),
+			args:        args{car: values().car0},
+			want:        0,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 2}),
 		},
 		{name: "Insert car beyond maxSlot",
-			carpark:     &Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 2},
+			carpark:     indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 2}),
 			args:        args{car: values().car0},
 			want:        0,
 			wantErr:     true,
-			wantCarpark: &Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 2},
+			wantCarpark: indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 2}),
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			tt.carpark.clock = &manualClock{now: values().now}
+			tt.carpark.slotUses = map[int]int{}
 			got, err := tt.carpark.insertCar(tt.args.car)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Carpark.insertCar() error = %v, wantErr %v", err, tt.wantErr)
@@ -155,34 +324,374 @@ func TestCarpark_removeCar(t *testing.T) {
 		name        string
 		carpark     *Carpark
 		args        args
+		want        *Car
 		wantErr     bool
 		wantCarpark *Carpark
 	}{
 		{name: "Carpark not initialized",
 			carpark:     &Carpark{},
 			args:        args{slotNo: 1},
+			want:        nil,
 			wantErr:     true,
 			wantCarpark: &Carpark{},
 		},
 		{name: "Remove car",
-			carpark:     &Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10},
+			carpark:     indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, clock: &manualClock{now: values().now.Add(90 * time.Minute)}}),
 			args:        args{slotNo: 1},
+			want:        &Car{slot: 1, registration: "KA-01-HH-1234", colour: "White", entry: values().now, exit: values().now.Add(90 * time.Minute), ticket: "T000001"},
 			wantErr:     false,
-			wantCarpark: &Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10},
+			wantCarpark: indexed(&Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10}),
 		},
 		{name: "Remove non-existent car",
-			carpark:     &Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10},
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10}),
 			args:        args{slotNo: 2},
+			want:        nil,
 			wantErr:     true,
-			wantCarpark: &Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10},
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10}),
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := tt.carpark.removeCar(tt.args.slotNo); (err != nil) != tt.wantErr {
+			got, err := tt.carpark.removeCar(tt.args.slotNo)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("Carpark.removeCar() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Carpark.removeCar() = %v, want %v", got, tt.want)
+			}
+			compareCarpark(t, tt.carpark, tt.wantCarpark)
+		})
+	}
+}
+
+func TestCarpark_expand(t *testing.T) {
+	tests := []struct {
+		name        string
+		carpark     *Carpark
+		slots       int
+		wantErr     bool
+		wantCarpark *Carpark
+	}{
+		{name: "Carpark not initialized",
+			carpark:     &Carpark{},
+			slots:       2,
+			wantErr:     true,
+			wantCarpark: &Carpark{},
+		},
+		{name: "Expand top floor",
+			carpark:     indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 8, floors: []int{4, 4}}),
+			slots:       2,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, floors: []int{4, 6}}),
+		},
+		{name: "Invalid number of slots",
+			carpark:     indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 8, floors: []int{4, 4}}),
+			slots:       -2,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 8, floors: []int{4, 4}}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.carpark.expand(tt.slots); (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.expand() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			compareCarpark(t, tt.carpark, tt.wantCarpark)
+		})
+	}
+}
+
+func TestCarpark_shrink(t *testing.T) {
+	tests := []struct {
+		name        string
+		carpark     *Carpark
+		slots       int
+		wantErr     bool
+		wantCarpark *Carpark
+	}{
+		{name: "Carpark not initialized",
+			carpark:     &Carpark{},
+			slots:       2,
+			wantErr:     true,
+			wantCarpark: &Carpark{},
+		},
+		{name: "Shrink slots never occupied",
+			carpark:     indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, floors: []int{10}}),
+			slots:       8,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 2, floors: []int{2}}),
+		},
+		{name: "Shrink slots previously occupied",
+			carpark: indexed(&Carpark{Map: values().map1, highestSlot: 3, maxSlot: 10, floors: []int{10},
+				emptySlot: emptySlots(2, 3), slotClass: map[int]VehicleClass{3: vanClass}}),
+			slots:       8,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 2, floors: []int{2}, slotClass: map[int]VehicleClass{}}),
+		},
+		{name: "Shrink across floors",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 8, floors: []int{4, 4}}),
+			slots:       5,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 3, floors: []int{3}}),
+		},
+		{name: "Shrink occupied slots",
+			carpark:     indexed(&Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10, floors: []int{10}}),
+			slots:       9,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10, floors: []int{10}}),
+		},
+		{name: "Shrink all slots",
+			carpark:     indexed(&Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 0, maxSlot: 10, floors: []int{10}}),
+			slots:       10,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 0, maxSlot: 10, floors: []int{10}}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.carpark.shrink(tt.slots); (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.shrink() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			compareCarpark(t, tt.carpark, tt.wantCarpark)
+		})
+	}
+}
+
+func TestCarpark_closeSlot(t *testing.T) {
+	tests := []struct {
+		name        string
+		carpark     *Carpark
+		slotNo      int
+		wantErr     bool
+		wantCarpark *Carpark
+	}{
+		{name: "Carpark not initialized",
+			carpark:     &Carpark{},
+			slotNo:      1,
+			wantErr:     true,
+			wantCarpark: &Carpark{},
+		},
+		{name: "Close slot never occupied",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, closed: map[int]bool{}}),
+			slotNo:      7,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, closed: map[int]bool{7: true}}),
+		},
+		{name: "Close slot previously occupied",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10, closed: map[int]bool{}}),
+			slotNo:      2,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, closed: map[int]bool{2: true}}),
+		},
+		{name: "Close occupied slot",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, closed: map[int]bool{}}),
+			slotNo:      1,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, closed: map[int]bool{}}),
+		},
+		{name: "Close closed slot",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, closed: map[int]bool{7: true}}),
+			slotNo:      7,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, closed: map[int]bool{7: true}}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.carpark.closeSlot(tt.slotNo); (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.closeSlot() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			compareCarpark(t, tt.carpark, tt.wantCarpark)
+		})
+	}
+}
+
+func TestCarpark_openSlot(t *testing.T) {
+	tests := []struct {
+		name        string
+		carpark     *Carpark
+		slotNo      int
+		wantErr     bool
+		wantCarpark *Carpark
+	}{
+		{name: "Carpark not initialized",
+			carpark:     &Carpark{},
+			slotNo:      1,
+			wantErr:     true,
+			wantCarpark: &Carpark{},
+		},
+		{name: "Open slot never occupied",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, closed: map[int]bool{7: true}}),
+			slotNo:      7,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, closed: map[int]bool{}}),
+		},
+		{name: "Open slot passed over while closed",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 3, maxSlot: 10, closed: map[int]bool{2: true}}),
+			slotNo:      2,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 3, maxSlot: 10, closed: map[int]bool{}}),
+		},
+		{name: "Open slot which is not closed",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, closed: map[int]bool{}}),
+			slotNo:      1,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, closed: map[int]bool{}}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.carpark.openSlot(tt.slotNo); (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.openSlot() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			compareCarpark(t, tt.carpark, tt.wantCarpark)
+		})
+	}
+}
+
+func TestCarpark_removeCarWithTicket(t *testing.T) {
+	tests := []struct {
+		name        string
+		carpark     *Carpark
+		ticket      string
+		want        *Car
+		wantErr     bool
+		wantCarpark *Carpark
+	}{
+		{name: "Carpark not initialized",
+			carpark:     &Carpark{},
+			ticket:      "T000001",
+			want:        nil,
+			wantErr:     true,
+			wantCarpark: &Carpark{},
+		},
+		{name: "Valid ticket",
+			carpark:     indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, clock: &manualClock{now: values().now}}),
+			ticket:      "T000002",
+			want:        &Car{slot: 2, registration: "KA-01-HH-7777", colour: "Red", entry: values().now, exit: values().now, ticket: "T000002"},
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10}),
+		},
+		{name: "Ticket of a car which has left",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10}),
+			ticket:      "T000002",
+			want:        nil,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.carpark.removeCarWithTicket(tt.ticket)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.removeCarWithTicket() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Carpark.removeCarWithTicket() = %v, want %v", got, tt.want)
+			}
+			compareCarpark(t, tt.carpark, tt.wantCarpark)
+		})
+	}
+}
+
+func TestCarpark_verifyTicket(t *testing.T) {
+	type args struct {
+		slotNo int
+		ticket string
+	}
+	tests := []struct {
+		name    string
+		carpark *Carpark
+		args    args
+		wantErr bool
+	}{
+		{name: "Ticket of the car in the slot",
+			carpark: indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+			args:    args{slotNo: 1, ticket: "T000001"},
+			wantErr: false,
+		},
+		{name: "Ticket of another car",
+			carpark: indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+			args:    args{slotNo: 1, ticket: "T000002"},
+			wantErr: true,
+		},
+		{name: "Empty slot",
+			carpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10}),
+			args:    args{slotNo: 2, ticket: "T000002"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.carpark.verifyTicket(tt.args.slotNo, tt.args.ticket); (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.verifyTicket() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCarpark_setSlotClass(t *testing.T) {
+	type args struct {
+		slotNo int
+		class  VehicleClass
+	}
+	tests := []struct {
+		name        string
+		carpark     *Carpark
+		args        args
+		wantErr     bool
+		wantCarpark *Carpark
+	}{
+		{name: "Carpark not initialized",
+			carpark:     &Carpark{},
+			args:        args{slotNo: 1, class: vanClass},
+			wantErr:     true,
+			wantCarpark: &Carpark{},
+		},
+		{name: "Slot never occupied",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, slotClass: map[int]VehicleClass{}}),
+			args:        args{slotNo: 5, class: vanClass},
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, slotClass: map[int]VehicleClass{5: vanClass}}),
+		},
+		{name: "Slot previously occupied",
+			carpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10, slotClass: map[int]VehicleClass{}}),
+			args:    args{slotNo: 2, class: busClass},
+			wantErr: false,
+			wantCarpark: indexed(&Carpark{Map: values().map1, highestSlot: 2, maxSlot: 10, slotClass: map[int]VehicleClass{2: busClass},
+				emptySlot: classEmptySlots(busClass, 2)}),
+		},
+		{name: "Slot reset to car slot",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, slotClass: map[int]VehicleClass{5: vanClass}}),
+			args:        args{slotNo: 5, class: carClass},
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, slotClass: map[int]VehicleClass{}}),
+		},
+		{name: "Occupied slot",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, slotClass: map[int]VehicleClass{}}),
+			args:        args{slotNo: 1, class: vanClass},
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, slotClass: map[int]VehicleClass{}}),
+		},
+		{name: "Slot beyond maxSlot",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, slotClass: map[int]VehicleClass{}}),
+			args:        args{slotNo: 11, class: vanClass},
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, slotClass: map[int]VehicleClass{}}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.carpark.setSlotClass(tt.args.slotNo, tt.args.class); (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.setSlotClass() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
 			compareCarpark(t, tt.carpark, tt.wantCarpark)
 		})
 	}
@@ -201,21 +710,28 @@ func TestCarpark_getCarsWithColour(t *testing.T) {
 		wantErr bool
 	}{
 		{name: "Carpark with car of requested colour",
-			carpark: &Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10},
+			carpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
 			args:    args{colour: "White"},
 			want:    []int{1},
 			want1:   []string{"KA-01-HH-1234"},
 			wantErr: false,
 		},
+		{name: "Carpark with car of requested colour in another case",
+			carpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+			args:    args{colour: "WHITE"},
+			want:    []int{1},
+			want1:   []string{"KA-01-HH-1234"},
+			wantErr: false,
+		},
 		{name: "Carpark without car of requested colour",
-			carpark: &Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10},
+			carpark: indexed(&Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10}),
 			args:    args{colour: "White"},
 			want:    nil,
 			want1:   nil,
 			wantErr: true,
 		},
 		{name: "Empty carpark",
-			carpark: &Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 0, maxSlot: 10},
+			carpark: indexed(&Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 0, maxSlot: 10}),
 			args:    args{colour: "White"},
 			want:    nil,
 			want1:   nil,
@@ -246,6 +762,70 @@ func TestCarpark_getCarsWithColour(t *testing.T) {
 	}
 }
 
+//benchmarkCarpark parks cars in every slot of a carpark, where every hundredth car is green
+func benchmarkCarpark(b *testing.B, maxSlot int) *Carpark {
+	carpark := &Carpark{}
+	if err := carpark.init(maxSlot); err != nil {
+		b.Fatal(err)
+	}
+	for i := 1; i <= maxSlot; i++ {
+		colour := "White"
+		if i%100 == 0 {
+			colour = "Green"
+		}
+		if _, err := carpark.insertCar(&Car{registration: fmt.Sprintf("KA-01-HH-%06d", i), colour: colour}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return carpark
+}
+
+func BenchmarkCarpark_getCarsWithColour(b *testing.B) {
+	carpark := benchmarkCarpark(b, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := carpark.getCarsWithColour("Green"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkCarpark_scanCarsWithColour retrieves cars by colour by scanning every slot, as done before the colour index, for comparison
+func BenchmarkCarpark_scanCarsWithColour(b *testing.B) {
+	carpark := benchmarkCarpark(b, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var slots []int
+		var registrations []string
+		for slotNo := 1; slotNo <= carpark.highestSlot; slotNo++ {
+			car, ok := carpark.Map[slotNo]
+			if ok && car.colour == "Green" {
+				slots = append(slots, car.slot)
+				registrations = append(registrations, car.registration)
+			}
+		}
+		if slots == nil {
+			b.Fatal("Not found")
+		}
+	}
+}
+
+func BenchmarkCarpark_insertRemoveCar(b *testing.B) {
+	carpark := benchmarkCarpark(b, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		slotNo := (i*7919)%100000 + 1
+		car, err := carpark.removeCar(slotNo)
+		if err != nil {
+			b.Fatal(err)
+		}
+		car.exit = time.Time{}
+		if _, err := carpark.insertCar(car); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func TestCarpark_getCarWithRegistrationNo(t *testing.T) {
 	type args struct {
 		registration string
@@ -258,19 +838,25 @@ func TestCarpark_getCarWithRegistrationNo(t *testing.T) {
 		wantErr bool
 	}{
 		{name: "Carpark with car of requested colour",
-			carpark: &Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10},
+			carpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
 			args:    args{registration: "KA-01-HH-1234"},
 			want:    1,
 			wantErr: false,
 		},
+		{name: "Carpark with car of requested registration without separators",
+			carpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+			args:    args{registration: "ka01 hh1234"},
+			want:    1,
+			wantErr: false,
+		},
 		{name: "Carpark without car of requested colour",
-			carpark: &Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10},
+			carpark: indexed(&Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10}),
 			args:    args{registration: "KA-01-HH-1234"},
 			want:    0,
 			wantErr: true,
 		},
 		{name: "Empty carpark",
-			carpark: &Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 0, maxSlot: 10},
+			carpark: indexed(&Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 0, maxSlot: 10}),
 			args:    args{registration: "KA-01-HH-1234"},
 			want:    0,
 			wantErr: true,
@@ -296,6 +882,88 @@ func TestCarpark_getCarWithRegistrationNo(t *testing.T) {
 	}
 }
 
+func TestCarpark_getDurationWithRegistrationNo(t *testing.T) {
+	tests := []struct {
+		name         string
+		carpark      *Carpark
+		registration string
+		want         time.Duration
+		wantErr      bool
+	}{
+		{name: "Parked car",
+			carpark:      indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, clock: &manualClock{now: values().now.Add(125 * time.Minute)}}),
+			registration: "KA-01-HH-7777",
+			want:         125 * time.Minute,
+			wantErr:      false,
+		},
+		{name: "Car not parked",
+			carpark:      indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, clock: &manualClock{now: values().now}}),
+			registration: "KA-01-HH-7777",
+			want:         0,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.carpark.getDurationWithRegistrationNo(tt.registration)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.getDurationWithRegistrationNo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Carpark.getDurationWithRegistrationNo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCarpark_setTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		carpark *Carpark
+		now     time.Time
+		wantErr bool
+	}{
+		{name: "Manual clock forwards",
+			carpark: &Carpark{clock: &manualClock{now: values().now}},
+			now:     values().now.Add(time.Hour),
+			wantErr: false,
+		},
+		{name: "Manual clock backwards",
+			carpark: &Carpark{clock: &manualClock{now: values().now}},
+			now:     values().now.Add(-time.Hour),
+			wantErr: true,
+		},
+		{name: "System clock forwards",
+			carpark: &Carpark{},
+			now:     time.Now().Add(time.Hour),
+			wantErr: false,
+		},
+		{name: "System clock to a past time",
+			carpark: &Carpark{},
+			now:     values().now,
+			wantErr: false,
+		},
+		{name: "System clock before the last event",
+			carpark: &Carpark{events: []*event{{kind: parkEvent, time: values().now.Add(time.Hour)}}},
+			now:     values().now,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.carpark.setTime(tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.setTime() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !tt.carpark.now().Equal(tt.now) {
+				t.Errorf("Carpark.setTime() now = %v, want %v", tt.carpark.now(), tt.now)
+			}
+		})
+	}
+}
+
 func TestCarpark_getStatus(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -307,11 +975,11 @@ func TestCarpark_getStatus(t *testing.T) {
 			want:    nil,
 		},
 		{name: "Empty carpark",
-			carpark: &Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 0, maxSlot: 10},
+			carpark: indexed(&Carpark{Map: values().map0, emptySlot: values().emptySlot0, highestSlot: 0, maxSlot: 10}),
 			want:    nil,
 		},
 		{name: "Carpark with cars",
-			carpark: &Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10},
+			carpark: indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
 			want:    []*Car{values().car1, values().car2},
 		},
 	}
@@ -323,3 +991,88 @@ func TestCarpark_getStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestCarpark_slotLabel(t *testing.T) {
+	tests := []struct {
+		name    string
+		carpark *Carpark
+		slotNo  int
+		want    string
+	}{
+		{name: "Single floor carpark",
+			carpark: &Carpark{maxSlot: 10, floors: []int{10}},
+			slotNo:  7,
+			want:    "7",
+		},
+		{name: "Ground floor of multi-level carpark",
+			carpark: &Carpark{maxSlot: 30, floors: []int{10, 20}},
+			slotNo:  10,
+			want:    "1-10",
+		},
+		{name: "Upper floor of multi-level carpark",
+			carpark: &Carpark{maxSlot: 30, floors: []int{10, 20}},
+			slotNo:  27,
+			want:    "2-17",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.carpark.slotLabel(tt.slotNo); got != tt.want {
+				t.Errorf("Carpark.slotLabel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCarpark_parseSlot(t *testing.T) {
+	tests := []struct {
+		name    string
+		carpark *Carpark
+		id      string
+		want    int
+		wantErr bool
+	}{
+		{name: "Plain slot number",
+			carpark: &Carpark{maxSlot: 30, floors: []int{10, 20}},
+			id:      "27",
+			want:    27,
+			wantErr: false,
+		},
+		{name: "Floor and slot",
+			carpark: &Carpark{maxSlot: 30, floors: []int{10, 20}},
+			id:      "2-17",
+			want:    27,
+			wantErr: false,
+		},
+		{name: "Non-existent floor",
+			carpark: &Carpark{maxSlot: 30, floors: []int{10, 20}},
+			id:      "3-1",
+			want:    0,
+			wantErr: true,
+		},
+		{name: "Slot beyond floor size",
+			carpark: &Carpark{maxSlot: 30, floors: []int{10, 20}},
+			id:      "1-11",
+			want:    0,
+			wantErr: true,
+		},
+		{name: "Malformed slot",
+			carpark: &Carpark{maxSlot: 30, floors: []int{10, 20}},
+			id:      "MH-04",
+			want:    0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.carpark.parseSlot(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.parseSlot() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Carpark.parseSlot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
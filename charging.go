@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+//charger is a simulated EV charger installed at a slot
+type charger struct {
+	power    float64       //Power delivered in kW
+	price    int           //Price of energy in cents per kWh
+	since    time.Time     //Time at which the charger was installed
+	sessions int           //Number of completed charging sessions
+	busy     time.Duration //Total time of completed charging sessions
+	energy   float64       //Total energy delivered by completed charging sessions in kWh
+}
+
+//chargeSession is a completed charging session of a car
+type chargeSession struct {
+	slot     int           //Slot number of the charger
+	duration time.Duration //Time spent charging
+	energy   float64       //Energy delivered in kWh
+	cost     int           //Cost of the energy in cents
+}
+
+//Install a charger of the given power and price at a slot, designating the slot as an EV slot, or change the power
+//and price of the charger already installed while keeping its usage
+func (carpark *Carpark) setCharger(slotNo int, power float64, price int) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	if slotNo < 1 || slotNo > carpark.maxSlot {
+		return errors.New("Invalid slot number")
+	}
+	if power <= 0 || math.IsNaN(power) || math.IsInf(power, 0) || price < 0 {
+		return errors.New("Invalid charger")
+	}
+	if car, ok := carpark.Map[slotNo]; ok && !car.chargeStart.IsZero() {
+		return errors.New("Slot is charging")
+	}
+	if point, ok := carpark.chargers[slotNo]; ok {
+		point.power, point.price = power, price
+	} else {
+		carpark.chargers[slotNo] = &charger{power: power, price: price, since: carpark.now()}
+	}
+	carpark.attributes[slotNo] |= evAttribute
+	return carpark.record(journalRecord{Op: "set_charger", Time: carpark.now(), Slot: slotNo, Power: power, Price: price})
+}
+
+//Start charging the car parked in a slot with a charger
+func (carpark *Carpark) startCharge(slotNo int) (*Car, error) {
+	if err := carpark.initStatus(); err != nil {
+		return nil, err
+	}
+	car, ok := carpark.Map[slotNo]
+	if !ok {
+		return nil, errors.New("Car non-existent in carpark")
+	}
+	if _, ok := carpark.chargers[slotNo]; !ok {
+		return nil, errors.New("Slot has no charger")
+	}
+	if !car.chargeStart.IsZero() {
+		return nil, errors.New("Car is already charging")
+	}
+	car.chargeStart = carpark.now()
+	return car, carpark.record(journalRecord{Op: "start_charge", Time: car.chargeStart, Slot: slotNo})
+}
+
+//Stop charging the car parked in a slot, recording the session on the car and the charger
+func (carpark *Carpark) stopCharge(slotNo int) (chargeSession, error) {
+	if err := carpark.initStatus(); err != nil {
+		return chargeSession{}, err
+	}
+	car, ok := carpark.Map[slotNo]
+	if !ok {
+		return chargeSession{}, errors.New("Car non-existent in carpark")
+	}
+	if car.chargeStart.IsZero() {
+		return chargeSession{}, errors.New("Car is not charging")
+	}
+	session := carpark.endCharge(car)
+	return session, carpark.record(journalRecord{Op: "stop_charge", Time: carpark.now(), Slot: slotNo})
+}
+
+//End the charging session of a car, recording the energy delivered at the power of its charger
+func (carpark *Carpark) endCharge(car *Car) chargeSession {
+	point := carpark.chargers[car.slot]
+	duration := carpark.now().Sub(car.chargeStart)
+	energy := point.power * duration.Hours()
+	session := chargeSession{
+		slot:     car.slot,
+		duration: duration,
+		energy:   energy,
+		cost:     int(math.Round(energy * float64(point.price))),
+	}
+	car.chargeStart = time.Time{}
+	car.chargeTime += duration
+	car.energy += energy
+	car.chargeCost += session.cost
+	point.sessions++
+	point.busy += duration
+	point.energy += energy
+	return session
+}
+
+//Retrieve the time a charger has spent charging since it was installed, including the session in progress
+func (carpark *Carpark) chargerBusy(slotNo int) time.Duration {
+	busy := carpark.chargers[slotNo].busy
+	if car, ok := carpark.Map[slotNo]; ok && !car.chargeStart.IsZero() {
+		busy += carpark.now().Sub(car.chargeStart)
+	}
+	return busy
+}
+
+//Retrieve the share of time each charger has spent charging since it was installed, including sessions in progress
+func (carpark *Carpark) chargerUtilisation(slotNo int) float64 {
+	installed := carpark.now().Sub(carpark.chargers[slotNo].since)
+	if installed <= 0 {
+		return 0
+	}
+	return float64(carpark.chargerBusy(slotNo)) / float64(installed)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCarpark_setCharger(t *testing.T) {
+	now := values().now
+	installed := func() map[int]*charger {
+		return map[int]*charger{1: {power: 7, price: 30, since: now.Add(-time.Hour), sessions: 2, busy: time.Hour, energy: 7}}
+	}
+	tests := []struct {
+		name     string
+		chargers map[int]*charger
+		slotNo   int
+		power    float64
+		wantErr  bool
+		want     *charger
+	}{
+		{name: "New charger",
+			chargers: map[int]*charger{},
+			slotNo:   1,
+			power:    11,
+			wantErr:  false,
+			want:     &charger{power: 11, price: 40, since: now},
+		},
+		{name: "Charger installed again",
+			chargers: installed(),
+			slotNo:   1,
+			power:    11,
+			wantErr:  false,
+			want:     &charger{power: 11, price: 40, since: now.Add(-time.Hour), sessions: 2, busy: time.Hour, energy: 7},
+		},
+		{name: "Invalid slot",
+			chargers: map[int]*charger{},
+			slotNo:   3,
+			power:    11,
+			wantErr:  true,
+		},
+		{name: "No power",
+			chargers: map[int]*charger{},
+			slotNo:   1,
+			power:    0,
+			wantErr:  true,
+		},
+		{name: "Power not a number",
+			chargers: map[int]*charger{},
+			slotNo:   1,
+			power:    math.NaN(),
+			wantErr:  true,
+		},
+		{name: "Infinite power",
+			chargers: map[int]*charger{},
+			slotNo:   1,
+			power:    math.Inf(1),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carpark := &Carpark{Map: values().map0, maxSlot: 2, chargers: tt.chargers, attributes: map[int]SlotAttributes{}, clock: &manualClock{now: now}}
+			err := carpark.setCharger(tt.slotNo, tt.power, 40)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.setCharger() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(carpark.chargers[tt.slotNo], tt.want) || carpark.attributes[tt.slotNo] != evAttribute {
+				t.Errorf("charger = %v, want %v", carpark.chargers[tt.slotNo], tt.want)
+			}
+		})
+	}
+}
+
+func TestCarpark_startCharge(t *testing.T) {
+	now := values().now
+	tests := []struct {
+		name    string
+		carpark *Carpark
+		slotNo  int
+		wantErr bool
+	}{
+		{name: "Carpark not initialized",
+			carpark: &Carpark{},
+			slotNo:  1,
+			wantErr: true,
+		},
+		{name: "Car at a charger",
+			carpark: &Carpark{Map: values().map1, chargers: map[int]*charger{1: {power: 7, since: now}}},
+			slotNo:  1,
+			wantErr: false,
+		},
+		{name: "Slot without a charger",
+			carpark: &Carpark{Map: values().map1, chargers: map[int]*charger{2: {power: 7, since: now}}},
+			slotNo:  1,
+			wantErr: true,
+		},
+		{name: "Empty slot",
+			carpark: &Carpark{Map: values().map1, chargers: map[int]*charger{2: {power: 7, since: now}}},
+			slotNo:  2,
+			wantErr: true,
+		},
+		{name: "Car already charging",
+			carpark: &Carpark{Map: map[int]*Car{1: {slot: 1, chargeStart: now}}, chargers: map[int]*charger{1: {power: 7, since: now}}},
+			slotNo:  1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.carpark.clock = &manualClock{now: now}
+			car, err := tt.carpark.startCharge(tt.slotNo)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.startCharge() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !car.chargeStart.Equal(now) {
+				t.Errorf("chargeStart = %v, want %v", car.chargeStart, now)
+			}
+		})
+	}
+}
+
+func TestCarpark_stopCharge(t *testing.T) {
+	now := values().now
+	tests := []struct {
+		name        string
+		car         *Car
+		charger     *charger
+		wantSession chargeSession
+		wantErr     bool
+		wantCar     *Car
+		wantCharger *charger
+	}{
+		{name: "Charge for 90 minutes",
+			car:         &Car{slot: 1, chargeStart: now.Add(-90 * time.Minute), energy: 1, chargeTime: time.Hour, chargeCost: 30},
+			charger:     &charger{power: 7, price: 30, since: now.Add(-3 * time.Hour), sessions: 1, busy: time.Hour, energy: 1},
+			wantSession: chargeSession{slot: 1, duration: 90 * time.Minute, energy: 10.5, cost: 315},
+			wantErr:     false,
+			wantCar:     &Car{slot: 1, energy: 11.5, chargeTime: 150 * time.Minute, chargeCost: 345},
+			wantCharger: &charger{power: 7, price: 30, since: now.Add(-3 * time.Hour), sessions: 2, busy: 150 * time.Minute, energy: 11.5},
+		},
+		{name: "Car not charging",
+			car:         &Car{slot: 1},
+			charger:     &charger{power: 7, price: 30, since: now},
+			wantErr:     true,
+			wantCar:     &Car{slot: 1},
+			wantCharger: &charger{power: 7, price: 30, since: now},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carpark := &Carpark{Map: map[int]*Car{1: tt.car}, chargers: map[int]*charger{1: tt.charger}, clock: &manualClock{now: now}}
+			got, err := carpark.stopCharge(1)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.stopCharge() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.wantSession) {
+				t.Errorf("Carpark.stopCharge() = %v, want %v", got, tt.wantSession)
+			}
+			if !reflect.DeepEqual(tt.car, tt.wantCar) || !reflect.DeepEqual(tt.charger, tt.wantCharger) {
+				t.Errorf("car = %v, charger = %v, want %v, %v", tt.car, tt.charger, tt.wantCar, tt.wantCharger)
+			}
+		})
+	}
+}
+
+func TestCarpark_chargerUtilisation(t *testing.T) {
+	now := values().now
+	tests := []struct {
+		name    string
+		carpark *Carpark
+		want    float64
+	}{
+		{name: "Completed sessions",
+			carpark: &Carpark{Map: values().map0, chargers: map[int]*charger{1: {power: 7, since: now.Add(-4 * time.Hour), busy: time.Hour}}},
+			want:    0.25,
+		},
+		{name: "Session in progress",
+			carpark: &Carpark{Map: map[int]*Car{1: {slot: 1, chargeStart: now.Add(-time.Hour)}}, chargers: map[int]*charger{1: {power: 7, since: now.Add(-4 * time.Hour), busy: time.Hour}}},
+			want:    0.5,
+		},
+		{name: "Charger just installed",
+			carpark: &Carpark{Map: values().map0, chargers: map[int]*charger{1: {power: 7, since: now}}},
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.carpark.clock = &manualClock{now: now}
+			if got := tt.carpark.chargerUtilisation(1); got != tt.want {
+				t.Errorf("Carpark.chargerUtilisation() = %v, want %v", got, tt.want)
+			}
+			if got, want := tt.carpark.chargerBusy(1), time.Duration(tt.want*4*float64(time.Hour)); got != want && tt.want != 0 {
+				t.Errorf("Carpark.chargerBusy() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+//timeLayout is the format of times given to and printed by the carpark commands
+const timeLayout = "2006-01-02T15:04"
+
+//Clock provides the current time to the carpark
+type Clock interface {
+	Now() time.Time
+}
+
+//systemClock reports the time of the operating system
+type systemClock struct{}
+
+//Now returns the current system time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+//manualClock reports a time which is set explicitly, such as by the time command in file input mode
+type manualClock struct {
+	now time.Time
+}
+
+//Now returns the time last set on the clock
+func (clock *manualClock) Now() time.Time {
+	return clock.now
+}
+
+//Retrieve the current time from the carpark clock, which defaults to the system clock
+func (carpark *Carpark) now() time.Time {
+	if carpark.clock == nil {
+		return time.Now()
+	}
+	return carpark.clock.Now()
+}
+
+//Run an operation with the carpark clock stopped at the given time, such as to repeat an operation made at that time
+func (carpark *Carpark) at(now time.Time, operation func() error) error {
+	clock := carpark.clock
+	carpark.clock = &manualClock{now: now}
+	defer func() { carpark.clock = clock }()
+	return operation()
+}
+
+//Set the carpark time explicitly, switching the carpark to a manual clock. The time may be set in the past when
+//switching from the system clock, but never before the last event, nor back once set.
+func (carpark *Carpark) setTime(now time.Time) error {
+	clock, ok := carpark.clock.(*manualClock)
+	if ok && now.Before(clock.now) {
+		return errors.New("Time cannot be set backwards")
+	}
+	if n := len(carpark.events); n > 0 && now.Before(carpark.events[n-1].time) {
+		return errors.New("Time cannot be set before the last car parked, moved or left")
+	}
+	if !ok {
+		carpark.clock = &manualClock{now: now}
+		return nil
+	}
+	clock.now = now
+	return nil
+}
+
+//formatDuration prints a duration in hours and minutes, such as 26h05m
+func formatDuration(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
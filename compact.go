@@ -0,0 +1,94 @@
+package main
+
+//move is a relocation of the car in one slot to another slot
+type move struct {
+	from int //Slot number the car is moved from
+	to   int //Slot number the car is moved to
+}
+
+//Plan the moves packing cars toward the entry. Cars are taken from the highest slot down, and each is moved
+//once, to the empty slot below it of the smallest class which suits it, and the lowest of those, keeping larger
+//slots for the larger vehicles below. Planning stops at the first car which cannot be moved, or the first
+//reserved slot, as moving the cars below them would not lower the highest slot used.
+func (carpark *Carpark) compactPlan() []move {
+	carpark.expireReservations()
+	var holes []int
+	for slotNo := 1; slotNo <= carpark.highestSlot; slotNo++ {
+		if carpark.checkEmptySlot(slotNo) == nil {
+			holes = append(holes, slotNo)
+		}
+	}
+	var plan []move
+	for slotNo := carpark.highestSlot; slotNo > 0 && len(holes) > 0; slotNo-- {
+		if _, reserved := carpark.reserved[slotNo]; reserved {
+			return plan
+		}
+		car, ok := carpark.Map[slotNo]
+		if !ok {
+			continue
+		}
+		if !car.chargeStart.IsZero() {
+			return plan
+		}
+		best := -1
+		for i := 0; i < len(holes) && holes[i] < slotNo; i++ {
+			if carpark.suits(car, holes[i]) && (best < 0 ||
+				classSizes[carpark.slotClass[holes[i]]] < classSizes[carpark.slotClass[holes[best]]]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return plan
+		}
+		plan = append(plan, move{from: slotNo, to: holes[best]})
+		holes = append(holes[:best], holes[best+1:]...)
+	}
+	return plan
+}
+
+//Check whether a slot suits a car when compacting, which is when the car fits the slot and the slot has the
+//attributes the car requires and no attributes the car does not ask for
+func (carpark *Carpark) suits(car *Car, slotNo int) bool {
+	attributes := carpark.attributes[slotNo]
+	return car.class.fits(carpark.slotClass[slotNo]) &&
+		attributes&car.required == car.required &&
+		attributes&^(car.required|car.preferred) == 0
+}
+
+//Apply the moves packing cars toward the entry, and lower highestSlot below the empty slots left at the top
+func (carpark *Carpark) compact() ([]move, error) {
+	if err := carpark.initStatus(); err != nil {
+		return nil, err
+	}
+	var moved []move
+	for _, step := range carpark.compactPlan() {
+		if _, err := carpark.moveCar(step.from, step.to); err != nil {
+			return moved, err
+		}
+		moved = append(moved, step)
+	}
+	carpark.lowerHighestSlot()
+	return moved, carpark.record(journalRecord{Op: "lower_highest_slot", Time: carpark.now()})
+}
+
+//Lower highestSlot below the empty slots at the top of the slots used so far, withdrawing them from the heaps of
+//empty slots so that they are allocated again in order
+func (carpark *Carpark) lowerHighestSlot() {
+	lowering := &lowering{highest: carpark.highestSlot}
+	defer func() {
+		if carpark.highestSlot < lowering.highest {
+			carpark.track(lowering)
+		}
+	}()
+	for carpark.highestSlot > 0 {
+		slotNo := carpark.highestSlot
+		_, parked := carpark.Map[slotNo]
+		if _, reserved := carpark.reserved[slotNo]; parked || reserved {
+			return
+		}
+		if carpark.emptySlot[carpark.slotClass[slotNo]].Remove(slotNo) {
+			lowering.slots = append(lowering.slots, slotNo)
+		}
+		carpark.highestSlot--
+	}
+}
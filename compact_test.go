@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+//parked returns a map of cars parked in the given slots, each with its own registration number and ticket
+func parked(slots ...int) map[int]*Car {
+	cars := make(map[int]*Car)
+	for i, slotNo := range slots {
+		cars[slotNo] = &Car{slot: slotNo, registration: fmt.Sprintf("KA-01-HH-%04d", i+1), colour: "White", entry: values().now, ticket: fmt.Sprintf("T%06d", i+1)}
+	}
+	return cars
+}
+
+func TestCarpark_compactPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		carpark *Carpark
+		want    []move
+	}{
+		{name: "Compact carpark",
+			carpark: indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+			want:    nil,
+		},
+		{name: "Scattered cars",
+			carpark: indexed(&Carpark{Map: parked(2, 5, 7), emptySlot: emptySlots(1, 3, 4, 6), highestSlot: 7, maxSlot: 10}),
+			want:    []move{{from: 7, to: 1}, {from: 5, to: 3}},
+		},
+		{name: "Slot which the car does not fit",
+			carpark: indexed(&Carpark{Map: parked(3), emptySlot: emptySlots(2), highestSlot: 3, maxSlot: 10, slotClass: map[int]VehicleClass{1: motorcycleClass}}),
+			want:    []move{{from: 3, to: 2}},
+		},
+		{name: "Slot of the smallest class which fits",
+			carpark: indexed(&Carpark{Map: map[int]*Car{3: {slot: 3}, 4: {slot: 4, class: vanClass}, 5: {slot: 5}}, emptySlot: emptySlots(1, 2),
+				highestSlot: 5, maxSlot: 10, slotClass: map[int]VehicleClass{1: vanClass, 4: vanClass}}),
+			want: []move{{from: 5, to: 2}, {from: 4, to: 1}},
+		},
+		{name: "Slot with attributes the car does not ask for",
+			carpark: indexed(&Carpark{Map: parked(3), emptySlot: emptySlots(1, 2), highestSlot: 3, maxSlot: 10, attributes: map[int]SlotAttributes{1: accessibleAttribute}}),
+			want:    []move{{from: 3, to: 2}},
+		},
+		{name: "Closed and reserved slots",
+			carpark: indexed(&Carpark{Map: parked(4), emptySlot: emptySlots(3), highestSlot: 4, maxSlot: 10, closed: map[int]bool{1: true},
+				reserved: map[int]*reservation{2: {registration: "KA-01-HH-7777", slot: 2}}}),
+			want: []move{{from: 4, to: 3}},
+		},
+		{name: "Car below a car which is charging",
+			carpark: indexed(&Carpark{Map: map[int]*Car{2: {slot: 2}, 5: {slot: 5, chargeStart: values().now}}, emptySlot: emptySlots(1, 3, 4), highestSlot: 5, maxSlot: 10}),
+			want:    nil,
+		},
+		{name: "Car below a reserved slot",
+			carpark: indexed(&Carpark{Map: parked(2), emptySlot: emptySlots(1, 3), highestSlot: 4, maxSlot: 10,
+				reserved: map[int]*reservation{4: {registration: "KA-01-HH-7777", slot: 4}}}),
+			want: nil,
+		},
+		{name: "Car below a car without a slot which suits it",
+			carpark: indexed(&Carpark{Map: parked(2, 4), emptySlot: emptySlots(1, 3), highestSlot: 4, maxSlot: 10, slotClass: map[int]VehicleClass{1: motorcycleClass, 3: motorcycleClass}}),
+			want:    nil,
+		},
+		{name: "Car below an expired reservation",
+			carpark: reservations(indexed(&Carpark{Map: parked(2), emptySlot: emptySlots(1), highestSlot: 3, maxSlot: 10, clock: &manualClock{now: values().now}}),
+				&reservation{registration: "KA-01-HH-7777", slot: 3, until: values().now}),
+			want: []move{{from: 2, to: 1}},
+		},
+		{name: "Car which is charging",
+			carpark: indexed(&Carpark{Map: map[int]*Car{3: {slot: 3, chargeStart: values().now}}, emptySlot: emptySlots(1, 2), highestSlot: 3, maxSlot: 10}),
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.carpark.compactPlan(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Carpark.compactPlan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCarpark_compact(t *testing.T) {
+	carpark := indexed(&Carpark{Map: parked(2, 5, 7), emptySlot: emptySlots(1, 3, 4, 6), highestSlot: 7, maxSlot: 10, slotUses: map[int]int{}})
+	cars := parked(2, 5, 7)
+	cars[7].slot, cars[5].slot = 1, 3
+	wantCarpark := indexed(&Carpark{Map: map[int]*Car{1: cars[7], 2: cars[2], 3: cars[5]}, emptySlot: emptySlots(), highestSlot: 3, maxSlot: 10})
+
+	got, err := carpark.compact()
+	if err != nil {
+		t.Fatalf("Carpark.compact() error = %v", err)
+	}
+	if want := []move{{from: 7, to: 1}, {from: 5, to: 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Carpark.compact() = %v, want %v", got, want)
+	}
+	compareCarpark(t, carpark, wantCarpark)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+//eventKind is the kind of change to the slot of a car recorded in the event log
+type eventKind int
+
+//Kinds of events, in the order they happen to a car during a visit
+const (
+	parkEvent eventKind = iota
+	moveEvent
+	leaveEvent
+	numEvents //Number of kinds of events
+)
+
+var eventNames = [numEvents]string{"park", "move", "leave"}
+
+//eventRetention is how long the events of a visit are kept after the car left
+const eventRetention = 90 * 24 * time.Hour
+
+//eventPruneInterval is the number of events logged between the pruning of expired visits from the event log
+const eventPruneInterval = 10000
+
+//String returns the name of the kind of event
+func (kind eventKind) String() string {
+	return eventNames[kind]
+}
+
+//parseEventKind converts the name of a kind of event into an eventKind
+func parseEventKind(name string) (eventKind, error) {
+	for kind, kindName := range eventNames {
+		if kindName == name {
+			return eventKind(kind), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown event %v", name)
+}
+
+//event is the parking, moving or leaving of a car recorded in the event log
+type event struct {
+	kind         eventKind
+	time         time.Time //Time of the event
+	slot         int       //Slot the car was parked at, moved to or left
+	registration string    //Registration number of the car
+	colour       string    //Colour of the car
+	ticket       string    //ID of the ticket issued to the car when it was parked
+}
+
+//visit is a stay of a car in the carpark, reconstructed from the event log
+type visit struct {
+	ticket string    //ID of the ticket issued to the car when it was parked
+	slots  []int     //Slots the car was parked at, in the order it was moved between them
+	entry  time.Time //Time at which the car was parked
+	exit   time.Time //Time at which the car left, which is zero while the car is parked
+}
+
+//Append an event of a car to the event log
+func (carpark *Carpark) logEvent(kind eventKind, car *Car, at time.Time) {
+	carpark.events = append(carpark.events, &event{
+		kind:         kind,
+		time:         at,
+		slot:         car.slot,
+		registration: car.registration,
+		colour:       car.colour,
+		ticket:       car.ticket,
+	})
+	if len(carpark.events)%eventPruneInterval == 0 {
+		carpark.pruneEvents()
+	}
+}
+
+//Forget the events of the visits which ended longer than eventRetention ago, keeping every event of cars still parked
+func (carpark *Carpark) pruneEvents() {
+	cutoff := carpark.now().Add(-eventRetention)
+	expired := make([]bool, len(carpark.events))
+	visits := make(map[string][]int) //Indexes of the events of the current visit of each car
+	for i, event := range carpark.events {
+		registration := normalizeRegistration(event.registration)
+		visits[registration] = append(visits[registration], i)
+		if event.kind == leaveEvent {
+			if event.time.Before(cutoff) {
+				for _, j := range visits[registration] {
+					expired[j] = true
+				}
+			}
+			delete(visits, registration)
+		}
+	}
+	kept := carpark.events[:0]
+	for i, event := range carpark.events {
+		if !expired[i] {
+			kept = append(kept, event)
+		}
+	}
+	carpark.events = kept
+}
+
+//Reconstruct the cars parked at a given time from the event log, in slot order
+func (carpark *Carpark) statusAt(at time.Time) ([]*Car, error) {
+	if err := carpark.initStatus(); err != nil {
+		return nil, err
+	}
+	if at.After(carpark.now()) {
+		return nil, errors.New("Time is in the future")
+	}
+	//Follow the cars by registration number, as cars swapping slots are moved one after the other
+	parked := make(map[string]*Car)
+	for _, event := range carpark.events {
+		//Skip later events, which are not always at the end when the clock was set backwards
+		if event.time.After(at) {
+			continue
+		}
+		registration := normalizeRegistration(event.registration)
+		switch event.kind {
+		case parkEvent:
+			parked[registration] = &Car{slot: event.slot, registration: event.registration, colour: event.colour, entry: event.time, ticket: event.ticket}
+		case moveEvent:
+			if car, ok := parked[registration]; ok {
+				car.slot = event.slot
+			}
+		case leaveEvent:
+			delete(parked, registration)
+		}
+	}
+	cars := make([]*Car, 0, len(parked))
+	for _, car := range parked {
+		cars = append(cars, car)
+	}
+	sort.Slice(cars, func(i, j int) bool { return cars[i].slot < cars[j].slot })
+	return cars, nil
+}
+
+//Reconstruct the visits of the car with the given registration number from the event log, in order of entry
+func (carpark *Carpark) history(registration string) ([]*visit, error) {
+	if err := carpark.initStatus(); err != nil {
+		return nil, err
+	}
+	registration = normalizeRegistration(registration)
+	var visits []*visit
+	for _, event := range carpark.events {
+		if normalizeRegistration(event.registration) != registration {
+			continue
+		}
+		switch event.kind {
+		case parkEvent:
+			visits = append(visits, &visit{ticket: event.ticket, slots: []int{event.slot}, entry: event.time})
+		case moveEvent:
+			if len(visits) > 0 {
+				last := visits[len(visits)-1]
+				last.slots = append(last.slots, event.slot)
+			}
+		case leaveEvent:
+			if len(visits) > 0 {
+				visits[len(visits)-1].exit = event.time
+			}
+		}
+	}
+	if len(visits) == 0 {
+		return nil, errors.New("Not found")
+	}
+	return visits, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+//eventfulCarpark parks, moves and removes cars, advancing the clock by an hour before each step
+func eventfulCarpark(t *testing.T) *Carpark {
+	clock := &manualClock{now: values().now}
+	carpark := &Carpark{clock: clock}
+	var steps []error
+	step := func(err error) {
+		steps = append(steps, err)
+		clock.now = clock.now.Add(time.Hour)
+	}
+	park := func(registration string) {
+		_, err := carpark.insertCar(&Car{registration: registration, colour: "White"})
+		step(err)
+	}
+	leave := func(slotNo int) {
+		_, err := carpark.removeCar(slotNo)
+		step(err)
+	}
+	step(carpark.init(5))
+	park("KA-01-HH-1234") //09:00 at slot 1
+	park("KA-01-HH-9999") //10:00 at slot 2
+	park("KA-01-BB-0001") //11:00 at slot 3
+	leave(1)              //12:00
+	_, err := carpark.moveCar(3, 4)
+	step(err)                    //13:00
+	step(carpark.swapCars(2, 4)) //14:00
+	park("KA-01-HH-1234")        //15:00 at slot 1
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("step %v error = %v", i, err)
+		}
+	}
+	return carpark
+}
+
+func TestCarpark_statusAt(t *testing.T) {
+	now := values().now
+	at := func(hour int) time.Time { return now.Add(time.Duration(hour-8) * time.Hour) }
+	tests := []struct {
+		name    string
+		at      time.Time
+		want    map[string]int
+		wantErr bool
+	}{
+		{name: "Before any car",
+			at:      at(8),
+			want:    map[string]int{},
+			wantErr: false,
+		},
+		{name: "Cars parked",
+			at:      at(11),
+			want:    map[string]int{"KA-01-HH-1234": 1, "KA-01-HH-9999": 2, "KA-01-BB-0001": 3},
+			wantErr: false,
+		},
+		{name: "Between events",
+			at:      at(12).Add(30 * time.Minute),
+			want:    map[string]int{"KA-01-HH-9999": 2, "KA-01-BB-0001": 3},
+			wantErr: false,
+		},
+		{name: "Car moved",
+			at:      at(13),
+			want:    map[string]int{"KA-01-HH-9999": 2, "KA-01-BB-0001": 4},
+			wantErr: false,
+		},
+		{name: "Cars swapped",
+			at:      at(14),
+			want:    map[string]int{"KA-01-HH-9999": 4, "KA-01-BB-0001": 2},
+			wantErr: false,
+		},
+		{name: "Car parked again",
+			at:      at(15),
+			want:    map[string]int{"KA-01-HH-1234": 1, "KA-01-HH-9999": 4, "KA-01-BB-0001": 2},
+			wantErr: false,
+		},
+		{name: "Future time",
+			at:      at(17),
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	carpark := eventfulCarpark(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cars, err := carpark.statusAt(tt.at)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.statusAt() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			got := make(map[string]int)
+			for i, car := range cars {
+				got[car.registration] = car.slot
+				if i > 0 && cars[i-1].slot >= car.slot {
+					t.Errorf("Carpark.statusAt() = %v, not in slot order", cars)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Carpark.statusAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := (&Carpark{}).statusAt(now); err == nil {
+		t.Errorf("Carpark.statusAt() of an uninitialized carpark error = %v, wantErr true", err)
+	}
+	//Events logged after the clock was set backwards follow later events
+	clock := &manualClock{now: at(10)}
+	carpark = &Carpark{clock: clock}
+	if err := carpark.init(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := carpark.insertCar(&Car{registration: "KA-01-HH-1234", colour: "White"}); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = at(9)
+	if _, err := carpark.insertCar(&Car{registration: "KA-01-HH-9999", colour: "White"}); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = at(11)
+	cars, err := carpark.statusAt(at(9).Add(30 * time.Minute))
+	if err != nil || len(cars) != 1 || cars[0].registration != "KA-01-HH-9999" {
+		t.Errorf("Carpark.statusAt() after the clock was set backwards = %v, %v, want KA-01-HH-9999", cars, err)
+	}
+}
+
+func TestCarpark_pruneEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		after time.Duration
+		want  int
+	}{
+		{name: "Visits not expired",
+			after: eventRetention + 4*time.Hour,
+			want:  8,
+		},
+		{name: "Finished visit expired",
+			after: eventRetention + 5*time.Hour,
+			want:  6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carpark := eventfulCarpark(t)
+			carpark.clock.(*manualClock).now = values().now.Add(tt.after)
+			carpark.pruneEvents()
+			if len(carpark.events) != tt.want {
+				t.Errorf("Carpark.pruneEvents() kept %v events, want %v", len(carpark.events), tt.want)
+			}
+		})
+	}
+}
+
+func TestCarpark_history(t *testing.T) {
+	now := values().now
+	at := func(hour int) time.Time { return now.Add(time.Duration(hour-8) * time.Hour) }
+	tests := []struct {
+		name         string
+		registration string
+		want         []*visit
+		wantErr      bool
+	}{
+		{name: "Car parked twice",
+			registration: "KA-01-HH-1234",
+			want: []*visit{
+				{ticket: "T000001", slots: []int{1}, entry: at(9), exit: at(12)},
+				{ticket: "T000004", slots: []int{1}, entry: at(15)},
+			},
+			wantErr: false,
+		},
+		{name: "Car moved and swapped",
+			registration: "ka01bb0001",
+			want: []*visit{
+				{ticket: "T000003", slots: []int{3, 4, 2}, entry: at(11)},
+			},
+			wantErr: false,
+		},
+		{name: "Car never parked",
+			registration: "KA-01-HH-2701",
+			want:         nil,
+			wantErr:      true,
+		},
+	}
+	carpark := eventfulCarpark(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := carpark.history(tt.registration)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.history() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Carpark.history() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+)
+
+//journalHeader is the size of the length, checksum of the record and checksum of the header preceding each record in the journal
+const journalHeader = 12
+
+//maxJournalRecord is the size of the largest record accepted when reading the journal
+const maxJournalRecord = 1 << 20
+
+//journalTable is the CRC-32 table of the checksums of journal records
+var journalTable = crc32.MakeTable(crc32.Castagnoli)
+
+//journal is an append-only log of the changes to the cars and slots of the carpark, synced to disk as each record is written
+type journal struct {
+	file *os.File
+	err  error //First error writing the journal, after which no more records are written
+}
+
+//journalRecord is a mutation of the carpark written to the journal
+type journalRecord struct {
+	Seq          int                  `json:"seq"`                    //Sequence number of the record, counting from 1
+	Op           string               `json:"op"`                     //Mutation, which is named after the method of the carpark making it
+	Time         time.Time            `json:"time"`                   //Time of the mutation
+	Floors       []int                `json:"floors,omitempty"`       //Number of slots on each floor of an initialized carpark
+	Car          *snapshotCar         `json:"car,omitempty"`          //Car which was parked, or put back by undoing its leaving
+	Slot         int                  `json:"slot,omitempty"`         //Slot which a car left or was moved from, or which was changed
+	To           int                  `json:"to,omitempty"`           //Slot which a car was moved to or swapped with
+	Slots        int                  `json:"slots,omitempty"`        //Number of slots added or removed
+	Class        string               `json:"class,omitempty"`        //Class which a slot was set to
+	Registration string               `json:"registration,omitempty"` //Registration number of the car which left, or of a reservation
+	Until        *time.Time           `json:"until,omitempty"`        //Time at which a reservation expires
+	Power        float64              `json:"power,omitempty"`        //Power of a charger installed in kW
+	Price        int                  `json:"price,omitempty"`        //Price of the energy of a charger installed in cents per kWh
+	Frontier     *journalFrontier     `json:"frontier,omitempty"`     //Frontier restored by undoing the allocation of a slot
+	Reservation  *snapshotReservation `json:"reservation,omitempty"`  //Reservation restored by undoing the parking of a car
+	Charger      *snapshotCharger     `json:"charger,omitempty"`      //Charger restored by undoing the leaving of a charging car
+}
+
+//journalFrontier is the on-disk format of the frontier before a slot was allocated
+type journalFrontier struct {
+	Highest int   `json:"highest"`
+	Passed  []int `json:"passed,omitempty"`
+	Draws   int64 `json:"draws,omitempty"` //Numbers drawn by the random strategy before the allocation
+}
+
+//newJournalFrontier captures the frontier before an allocation in the on-disk format
+func newJournalFrontier(before frontier) *journalFrontier {
+	return &journalFrontier{Highest: before.highest, Passed: before.passed, Draws: before.draws}
+}
+
+//Rebuild the frontier before an allocation from the journal, drawing from the source of the current random strategy
+func (carpark *Carpark) journaledFrontier(saved *journalFrontier) frontier {
+	before := carpark.frontier()
+	before.highest, before.passed = saved.Highest, saved.Passed
+	if before.source != nil {
+		before.draws = saved.Draws
+	}
+	return before
+}
+
+//Check that the last event logged is of the given kind for the given car, as an undone command removes it
+func (carpark *Carpark) checkLastEvent(kind eventKind, registration string) error {
+	if len(carpark.events) == 0 {
+		return errors.New("No event to undo")
+	}
+	if last := carpark.events[len(carpark.events)-1]; last.kind != kind || last.registration != registration {
+		return fmt.Errorf("Last event is not the %v of car %v", kind, registration)
+	}
+	return nil
+}
+
+//openJournal opens a journal for appending, creating it if it does not exist, and returns its records.
+//A record cut short or corrupted at the end of the journal, as left by a crash while writing it, is discarded.
+func openJournal(filename string) (*journal, []journalRecord, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	records, valid, err := readJournal(data)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	//Discard the incomplete record at the end of the journal
+	if valid < len(data) {
+		if err := file.Truncate(int64(valid)); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+	}
+	if _, err := file.Seek(int64(valid), io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return &journal{file: file}, records, nil
+}
+
+//readJournal decodes the records of a journal, returning the length of the journal up to the last complete record
+func readJournal(data []byte) ([]journalRecord, int, error) {
+	var records []journalRecord
+	offset := 0
+	for offset < len(data) {
+		if len(data)-offset < journalHeader {
+			break
+		}
+		//A complete header which does not match its checksum was corrupted after it was written
+		if crc32.Checksum(data[offset:offset+8], journalTable) != binary.BigEndian.Uint32(data[offset+8:]) {
+			return nil, 0, fmt.Errorf("Journal is corrupt at offset %v", offset)
+		}
+		size := int(binary.BigEndian.Uint32(data[offset:]))
+		sum := binary.BigEndian.Uint32(data[offset+4:])
+		end := offset + journalHeader + size
+		if size > maxJournalRecord {
+			return nil, 0, fmt.Errorf("Journal is corrupt at offset %v", offset)
+		}
+		if end > len(data) {
+			break
+		}
+		payload := data[offset+journalHeader : end]
+		if crc32.Checksum(payload, journalTable) != sum {
+			//Only the last record may have been left half written
+			if end < len(data) {
+				return nil, 0, fmt.Errorf("Journal is corrupt at offset %v", offset)
+			}
+			break
+		}
+		var record journalRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return nil, 0, fmt.Errorf("Journal is corrupt at offset %v: %v", offset, err)
+		}
+		records = append(records, record)
+		offset = end
+	}
+	return records, offset, nil
+}
+
+//append writes a record to the journal, returning once it is synced to disk
+func (journal *journal) append(record *journalRecord) error {
+	if journal.err != nil {
+		return journal.err
+	}
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(len(payload)))
+	binary.Write(&buf, binary.BigEndian, crc32.Checksum(payload, journalTable))
+	binary.Write(&buf, binary.BigEndian, crc32.Checksum(buf.Bytes(), journalTable))
+	buf.Write(payload)
+	if _, err := journal.file.Write(buf.Bytes()); err != nil {
+		journal.err = fmt.Errorf("Journal write failed: %v", err)
+		return journal.err
+	}
+	if err := journal.file.Sync(); err != nil {
+		journal.err = fmt.Errorf("Journal write failed: %v", err)
+		return journal.err
+	}
+	return nil
+}
+
+//reset empties the journal once its records are saved in a snapshot
+func (journal *journal) reset() error {
+	if journal.err != nil {
+		return journal.err
+	}
+	if err := journal.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := journal.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return journal.file.Sync()
+}
+
+//close closes the file of the journal
+func (journal *journal) close() error {
+	return journal.file.Close()
+}
+
+//Write a mutation of the carpark to its journal, if the carpark is journaled
+func (carpark *Carpark) record(record journalRecord) error {
+	if carpark.journal == nil {
+		return nil
+	}
+	record.Seq = carpark.journaled + 1
+	if err := carpark.journal.append(&record); err != nil {
+		return err
+	}
+	carpark.journaled = record.Seq
+	return nil
+}
+
+//Replay the records of a journal, skipping records already contained in the snapshot the carpark was restored from
+func (carpark *Carpark) replay(records []journalRecord) error {
+	for _, record := range records {
+		if record.Seq <= carpark.journaled {
+			continue
+		}
+		if record.Seq != carpark.journaled+1 {
+			return fmt.Errorf("Journal record %v is out of sequence", record.Seq)
+		}
+		//Replay the record at the time it was written
+		if err := carpark.at(record.Time, func() error { return carpark.apply(record) }); err != nil {
+			return fmt.Errorf("Cannot replay journal record %v: %v", record.Seq, err)
+		}
+		carpark.journaled = record.Seq
+	}
+	return nil
+}
+
+//Apply a mutation recorded in the journal, allocating the same slot as when it was recorded
+func (carpark *Carpark) apply(record journalRecord) error {
+	switch record.Op {
+	case "init":
+		return carpark.init(record.Floors...)
+	case "park":
+		if err := carpark.initStatus(); err != nil {
+			return err
+		}
+		if record.Car == nil {
+			return errors.New("Missing car")
+		}
+		car, err := record.Car.restore()
+		if err != nil {
+			return err
+		}
+		if _, ok := carpark.regs[normalizeRegistration(car.registration)]; ok {
+			return fmt.Errorf("Car %v is already parked", car.registration)
+		}
+		carpark.expireReservations()
+		if booking, ok := carpark.reservations[normalizeRegistration(car.registration)]; ok && booking.slot == car.slot {
+			carpark.dropReservation(booking)
+		}
+		if err := carpark.checkEmptySlot(car.slot); err != nil {
+			return err
+		}
+		if !car.class.fits(carpark.slotClass[car.slot]) {
+			return errors.New("Car does not fit the slot")
+		}
+		ticket := car.ticket
+		carpark.claimSlot(car.slot)
+		carpark.park(car, car.slot)
+		if car.ticket != ticket {
+			return fmt.Errorf("Ticket %v was issued instead of %v", car.ticket, ticket)
+		}
+		return nil
+	case "leave":
+		if car, ok := carpark.Map[record.Slot]; !ok || car.registration != record.Registration {
+			return fmt.Errorf("Car %v is not parked at slot number %v", record.Registration, record.Slot)
+		}
+		_, err := carpark.removeCar(record.Slot)
+		return err
+	case "move":
+		_, err := carpark.moveCar(record.Slot, record.To)
+		return err
+	case "swap":
+		return carpark.swapCars(record.Slot, record.To)
+	case "lower_highest_slot":
+		if err := carpark.initStatus(); err != nil {
+			return err
+		}
+		carpark.lowerHighestSlot()
+		return nil
+	case "expand":
+		return carpark.expand(record.Slots)
+	case "shrink":
+		return carpark.shrink(record.Slots)
+	case "set_slot_class":
+		class, err := parseVehicleClass(record.Class)
+		if err != nil {
+			return err
+		}
+		return carpark.setSlotClass(record.Slot, class)
+	case "close_slot":
+		return carpark.closeSlot(record.Slot)
+	case "open_slot":
+		return carpark.openSlot(record.Slot)
+	case "reserve":
+		if err := carpark.initStatus(); err != nil {
+			return err
+		}
+		if record.Until == nil {
+			return errors.New("Missing reservation expiry")
+		}
+		carpark.expireReservations()
+		if _, ok := carpark.reservations[normalizeRegistration(record.Registration)]; ok {
+			return fmt.Errorf("Car %v already has a reservation", record.Registration)
+		}
+		if err := carpark.checkEmptySlot(record.Slot); err != nil {
+			return err
+		}
+		carpark.claimSlot(record.Slot)
+		carpark.addReservation(&reservation{registration: record.Registration, slot: record.Slot, until: *record.Until})
+		return nil
+	case "cancel_reservation":
+		_, err := carpark.cancelReservation(record.Registration)
+		return err
+	case "set_charger":
+		return carpark.setCharger(record.Slot, record.Power, record.Price)
+	case "start_charge":
+		_, err := carpark.startCharge(record.Slot)
+		return err
+	case "stop_charge":
+		_, err := carpark.stopCharge(record.Slot)
+		return err
+	case "unpark":
+		car, ok := carpark.Map[record.Slot]
+		if !ok || car.registration != record.Registration {
+			return fmt.Errorf("Car %v is not parked at slot number %v", record.Registration, record.Slot)
+		}
+		if record.Frontier == nil {
+			return errors.New("Missing frontier")
+		}
+		if err := carpark.checkLastEvent(parkEvent, car.registration); err != nil {
+			return err
+		}
+		var booking *reservation
+		if saved := record.Reservation; saved != nil {
+			booking = &reservation{registration: saved.Registration, slot: saved.Slot, until: saved.Until}
+		}
+		carpark.unpark(car, carpark.journaledFrontier(record.Frontier), booking)
+		return nil
+	case "unleave":
+		if record.Car == nil {
+			return errors.New("Missing car")
+		}
+		car, err := record.Car.restore()
+		if err != nil {
+			return err
+		}
+		if _, ok := carpark.Map[car.slot]; ok {
+			return errors.New("Slot is occupied")
+		}
+		if err := carpark.checkLastEvent(leaveEvent, car.registration); err != nil {
+			return err
+		}
+		var point *charger
+		var pointBefore charger
+		if saved := record.Charger; saved != nil {
+			if point = carpark.chargers[car.slot]; point == nil {
+				return fmt.Errorf("No charger at slot number %v", car.slot)
+			}
+			pointBefore = charger{power: saved.Power, price: saved.Price, since: saved.Since, sessions: saved.Sessions, busy: saved.Busy, energy: saved.Energy}
+		}
+		carpark.unremoveCar(car, *car, point, pointBefore)
+		return nil
+	case "unmove":
+		car, ok := carpark.Map[record.To]
+		if !ok {
+			return errors.New("Car non-existent in carpark")
+		}
+		if _, ok := carpark.Map[record.Slot]; ok {
+			return errors.New("Slot is occupied")
+		}
+		if record.Frontier == nil {
+			return errors.New("Missing frontier")
+		}
+		if err := carpark.checkLastEvent(moveEvent, car.registration); err != nil {
+			return err
+		}
+		carpark.unmoveCar(car, record.Slot, record.To, carpark.journaledFrontier(record.Frontier))
+		return nil
+	case "unswap":
+		_, okA := carpark.Map[record.To]
+		carB, okB := carpark.Map[record.Slot]
+		if !okA || !okB {
+			return errors.New("Car non-existent in carpark")
+		}
+		//The car swapped into the first slot was moved last
+		if err := carpark.checkLastEvent(moveEvent, carB.registration); err != nil {
+			return err
+		}
+		carpark.unswapCars(record.Slot, record.To)
+		return nil
+	}
+	return fmt.Errorf("Unknown journal operation %v", record.Op)
+}
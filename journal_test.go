@@ -0,0 +1,555 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//journaledCarpark parks and removes cars in a carpark journaled to the given file,
+//returning the carpark and the size of the journal after each record
+func journaledCarpark(t *testing.T, filename string) (*Carpark, []int) {
+	journal, records, err := openJournal(filename)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("openJournal() = %v, %v", records, err)
+	}
+	clock := &manualClock{now: values().now}
+	carpark := &Carpark{clock: clock, journal: journal}
+	var sizes []int
+	step := func(err error) {
+		if err != nil {
+			t.Fatalf("step %v error = %v", len(sizes), err)
+		}
+		info, err := journal.file.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, int(info.Size()))
+		clock.now = clock.now.Add(10 * time.Minute)
+	}
+	park := func(car *Car) {
+		_, err := carpark.insertCar(car)
+		step(err)
+	}
+	leave := func(slotNo int) {
+		_, err := carpark.removeCar(slotNo)
+		step(err)
+	}
+	step(carpark.init(2, 3))
+	park(&Car{registration: "KA-01-HH-1234", colour: "White"})
+	park(&Car{registration: "KA-01-HH-9999", colour: "White", class: motorcycleClass})
+	park(&Car{registration: "KA-01-BB-0001", colour: "Black"})
+	park(&Car{registration: "KA-01-HH-7777", colour: "Red"})
+	leave(2)
+	leave(1)
+	park(&Car{registration: "KA-01-HH-2701", colour: "Blue"})
+	leave(4)
+	park(&Car{registration: "KA-01-HH-3141", colour: "Black"})
+	step(carpark.expand(2))
+	step(carpark.setSlotClass(6, vanClass))
+	step(carpark.closeSlot(4))
+	_, err = carpark.moveCar(3, 6)
+	step(err)
+	step(carpark.swapCars(1, 2))
+	step(carpark.openSlot(4))
+	park(&Car{registration: "KA-01-HH-5555", colour: "Blue"})
+	step(carpark.shrink(1))
+	return carpark, sizes
+}
+
+//compareReplayed verifies that a replayed carpark is in the same state as the journaled carpark
+func compareReplayed(t *testing.T, carpark *Carpark, wantCarpark *Carpark) {
+	compareCarpark(t, carpark, wantCarpark)
+	if carpark.ticketNo != wantCarpark.ticketNo ||
+		carpark.journaled != wantCarpark.journaled ||
+		!reflect.DeepEqual(carpark.slotUses, wantCarpark.slotUses) {
+		t.Errorf("replayed ticketNo, journaled, slotUses = %v, %v, %v, want %v, %v, %v", carpark.ticketNo, carpark.journaled, carpark.slotUses, wantCarpark.ticketNo, wantCarpark.journaled, wantCarpark.slotUses)
+	}
+}
+
+func TestCarpark_replay(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "carpark.journal")
+	carpark, _ := journaledCarpark(t, filename)
+	carpark.journal.close()
+	carpark.journal = nil
+
+	journal, records, err := openJournal(filename)
+	if err != nil {
+		t.Fatalf("openJournal() error = %v", err)
+	}
+	defer journal.close()
+	replayed := &Carpark{}
+	if err := replayed.replay(records); err != nil {
+		t.Fatalf("Carpark.replay() error = %v", err)
+	}
+	compareReplayed(t, replayed, carpark)
+
+	//The replayed carpark allocates the same slots
+	for _, registration := range []string{"KA-01-HH-0001", "KA-01-HH-0002", "KA-01-HH-0003"} {
+		want, wantErr := carpark.insertCar(&Car{registration: registration, colour: "Blue"})
+		got, err := replayed.insertCar(&Car{registration: registration, colour: "Blue"})
+		if got != want || (err != nil) != (wantErr != nil) {
+			t.Errorf("replayed Carpark.insertCar() = %v, %v, want %v, %v", got, err, want, wantErr)
+		}
+	}
+}
+
+func TestCarpark_replayCrash(t *testing.T) {
+	dir := t.TempDir()
+	carpark, sizes := journaledCarpark(t, filepath.Join(dir, "carpark.journal"))
+	carpark.journal.close()
+	data, err := os.ReadFile(filepath.Join(dir, "carpark.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Crash at every byte of the journal, as if the process died while writing the record
+	for cut := 0; cut <= len(data); cut++ {
+		filename := filepath.Join(dir, "crash.journal")
+		if err := os.WriteFile(filename, data[:cut], 0644); err != nil {
+			t.Fatal(err)
+		}
+		journal, records, err := openJournal(filename)
+		if err != nil {
+			t.Fatalf("openJournal() of %v bytes error = %v", cut, err)
+		}
+		journal.close()
+		complete, valid := 0, 0
+		for _, size := range sizes {
+			if size <= cut {
+				complete, valid = complete+1, size
+			}
+		}
+		if len(records) != complete {
+			t.Errorf("openJournal() of %v bytes = %v records, want %v", cut, len(records), complete)
+		}
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(info.Size()) != valid {
+			t.Errorf("journal of %v bytes truncated to %v, want %v", cut, info.Size(), valid)
+		}
+		replayed := &Carpark{}
+		if err := replayed.replay(records); err != nil {
+			t.Errorf("Carpark.replay() of %v bytes error = %v", cut, err)
+		}
+	}
+}
+
+func Test_openJournal(t *testing.T) {
+	dir := t.TempDir()
+	carpark, sizes := journaledCarpark(t, filepath.Join(dir, "carpark.journal"))
+	carpark.journal.close()
+	data, err := os.ReadFile(filepath.Join(dir, "carpark.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := func(offset int) []byte {
+		corrupted := append([]byte{}, data...)
+		corrupted[offset] ^= 0xff
+		return corrupted
+	}
+	tests := []struct {
+		name        string
+		data        []byte
+		wantRecords int
+		wantErr     bool
+	}{
+		{name: "Empty journal",
+			data:        nil,
+			wantRecords: 0,
+			wantErr:     false,
+		},
+		{name: "Complete journal",
+			data:        data,
+			wantRecords: len(sizes),
+			wantErr:     false,
+		},
+		{name: "Last record corrupted",
+			data:        corrupt(len(data) - 2),
+			wantRecords: len(sizes) - 1,
+			wantErr:     false,
+		},
+		{name: "Length of the last record corrupted",
+			data:        corrupt(sizes[len(sizes)-2]),
+			wantRecords: 0,
+			wantErr:     true,
+		},
+		{name: "Length of an earlier record corrupted",
+			data:        corrupt(sizes[1]),
+			wantRecords: 0,
+			wantErr:     true,
+		},
+		{name: "Earlier record corrupted",
+			data:        corrupt(sizes[0] + journalHeader + 2),
+			wantRecords: 0,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, "test.journal")
+			if err := os.WriteFile(filename, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			journal, records, err := openJournal(filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("openJournal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				journal.close()
+			}
+			if len(records) != tt.wantRecords {
+				t.Errorf("openJournal() = %v records, want %v", len(records), tt.wantRecords)
+			}
+		})
+	}
+}
+
+func TestCarpark_replaySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "carpark.journal")
+	carpark, _ := journaledCarpark(t, filename)
+	//Crash after saving a snapshot but before emptying the journal
+	if err := carpark.save(filepath.Join(dir, "state.json")); err != nil {
+		t.Fatalf("Carpark.save() error = %v", err)
+	}
+	if _, err := carpark.insertCar(&Car{registration: "KA-01-HH-0001", colour: "Blue"}); err != nil {
+		t.Fatalf("Carpark.insertCar() error = %v", err)
+	}
+	carpark.journal.close()
+
+	restored, err := loadCarpark(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("loadCarpark() error = %v", err)
+	}
+	journal, records, err := openJournal(filename)
+	if err != nil {
+		t.Fatalf("openJournal() error = %v", err)
+	}
+	defer journal.close()
+	if err := restored.replay(records); err != nil {
+		t.Fatalf("Carpark.replay() error = %v", err)
+	}
+	compareReplayed(t, restored, carpark)
+}
+
+func TestCarpark_replayReservationsAndCharging(t *testing.T) {
+	dir := t.TempDir()
+	journal, _, err := openJournal(filepath.Join(dir, "carpark.journal"))
+	if err != nil {
+		t.Fatalf("openJournal() error = %v", err)
+	}
+	clock := &manualClock{now: values().now}
+	carpark := &Carpark{clock: clock, journal: journal}
+	var steps []error
+	step := func(err error) {
+		steps = append(steps, err)
+		clock.now = clock.now.Add(10 * time.Minute)
+	}
+	park := func(registration string) {
+		_, err := carpark.insertCar(&Car{registration: registration, colour: "White"})
+		step(err)
+	}
+	reserve := func(registration string, slotNo int) {
+		_, err := carpark.reserve(registration, slotNo, values().now.Add(24*time.Hour))
+		step(err)
+	}
+	step(carpark.init(4))
+	reserve("KA-01-HH-0001", 1)
+	step(carpark.setCharger(2, 7.4, 30))
+	_, err = carpark.insertCar(&Car{registration: "KA-01-HH-1234", colour: "White", preferred: evAttribute})
+	step(err)
+	_, err = carpark.startCharge(2)
+	step(err)
+	//Save a snapshot, as on exit, after which the journal holds the records of the next run only
+	step(carpark.save(filepath.Join(dir, "state.json")))
+	step(carpark.journal.reset())
+	_, err = carpark.cancelReservation("KA-01-HH-0001")
+	step(err)
+	park("KA-01-HH-9999")
+	_, err = carpark.stopCharge(2)
+	step(err)
+	_, err = carpark.moveCar(2, 3)
+	step(err)
+	reserve("KA-01-HH-0002", 0)
+	step(carpark.setCharger(4, 11, 40))
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("step %v error = %v", i, err)
+		}
+	}
+	//Crash without saving a snapshot
+	carpark.journal.close()
+
+	restored, err := loadCarpark(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("loadCarpark() error = %v", err)
+	}
+	journal, records, err := openJournal(filepath.Join(dir, "carpark.journal"))
+	if err != nil {
+		t.Fatalf("openJournal() error = %v", err)
+	}
+	defer journal.close()
+	if err := restored.replay(records); err != nil {
+		t.Fatalf("Carpark.replay() error = %v", err)
+	}
+	compareReplayed(t, restored, carpark)
+	compareReservations(t, restored, carpark.reserved)
+	if !reflect.DeepEqual(restored.chargers, carpark.chargers) {
+		t.Errorf("replayed chargers = %v, want %v", restored.chargers, carpark.chargers)
+	}
+}
+
+func TestCarpark_replayUndo(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "carpark.journal")
+	journal, _, err := openJournal(filename)
+	if err != nil {
+		t.Fatalf("openJournal() error = %v", err)
+	}
+	clock := &manualClock{now: values().now}
+	carpark := &Carpark{clock: clock, journal: journal, tracking: true}
+	history := newUndoHistory(undoDepth)
+	var steps []error
+	step := func(input string, err error) {
+		steps = append(steps, err)
+		if changes := carpark.takeChanges(); len(changes) > 0 {
+			history.push(&command{input: input, changes: changes})
+		}
+		clock.now = clock.now.Add(10 * time.Minute)
+	}
+	park := func(registration string, preferred SlotAttributes) {
+		_, err := carpark.insertCar(&Car{registration: registration, colour: "White", preferred: preferred})
+		step("park", err)
+	}
+	undo := func() {
+		_, err := history.undo(carpark)
+		steps = append(steps, err)
+	}
+	redo := func() {
+		_, err := history.redo(carpark)
+		steps = append(steps, err)
+	}
+	step("create_parking_lot", carpark.init(6))
+	_, err = carpark.reserve("KA-01-HH-0001", 2, values().now.Add(24*time.Hour))
+	step("reserve", err)
+	step("set_charger", carpark.setCharger(3, 7.4, 30))
+	park("KA-01-HH-9999", evAttribute)
+	_, err = carpark.startCharge(3)
+	step("start_charge", err)
+	history.clear()
+	park("KA-01-HH-1234", 0)
+	park("KA-01-HH-0001", 0)
+	_, err = carpark.removeCar(3)
+	step("leave", err)
+	_, err = carpark.moveCar(1, 5)
+	step("move", err)
+	step("swap", carpark.swapCars(2, 5))
+	//Undo every command, the car with a reservation and the charging car included, then redo some of them
+	for i := 0; i < 5; i++ {
+		undo()
+	}
+	redo()
+	redo()
+	undo()
+	park("KA-01-HH-7777", 0)
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("step %v error = %v", i, err)
+		}
+	}
+	carpark.journal.close()
+	carpark.journal = nil
+
+	journal, records, err := openJournal(filename)
+	if err != nil {
+		t.Fatalf("openJournal() error = %v", err)
+	}
+	defer journal.close()
+	//Keep the reservation from expiring in the replayed carpark
+	replayed := &Carpark{clock: &manualClock{now: clock.now}}
+	if err := replayed.replay(records); err != nil {
+		t.Fatalf("Carpark.replay() error = %v", err)
+	}
+	compareReplayed(t, replayed, carpark)
+	compareReservations(t, replayed, carpark.reserved)
+	if !reflect.DeepEqual(replayed.chargers, carpark.chargers) || !reflect.DeepEqual(replayed.events, carpark.events) {
+		t.Errorf("replayed chargers, events = %v, %v, want %v, %v", replayed.chargers, replayed.events, carpark.chargers, carpark.events)
+	}
+
+	//The replayed carpark allocates the same slots
+	for _, registration := range []string{"KA-01-HH-0002", "KA-01-HH-0003", "KA-01-HH-0004"} {
+		want, wantErr := carpark.insertCar(&Car{registration: registration, colour: "Blue"})
+		got, err := replayed.insertCar(&Car{registration: registration, colour: "Blue"})
+		if got != want || (err != nil) != (wantErr != nil) {
+			t.Errorf("replayed Carpark.insertCar() = %v, %v, want %v, %v", got, err, want, wantErr)
+		}
+	}
+}
+
+func TestCarpark_apply(t *testing.T) {
+	now := values().now
+	until := now.Add(time.Hour)
+	tests := []struct {
+		name    string
+		record  journalRecord
+		wantErr bool
+	}{
+		{name: "Park a car",
+			record:  journalRecord{Seq: 3, Op: "park", Time: now, Car: &snapshotCar{Slot: 2, Registration: "KA-01-HH-2701", Colour: "Blue", Class: "car", Entry: now, Ticket: "T000002"}},
+			wantErr: false,
+		},
+		{name: "Park a car in an occupied slot",
+			record:  journalRecord{Seq: 3, Op: "park", Time: now, Car: &snapshotCar{Slot: 1, Registration: "KA-01-HH-2701", Colour: "Blue", Class: "car", Entry: now, Ticket: "T000002"}},
+			wantErr: true,
+		},
+		{name: "Park a car with another ticket",
+			record:  journalRecord{Seq: 3, Op: "park", Time: now, Car: &snapshotCar{Slot: 2, Registration: "KA-01-HH-2701", Colour: "Blue", Class: "car", Entry: now, Ticket: "T000005"}},
+			wantErr: true,
+		},
+		{name: "Remove a car",
+			record:  journalRecord{Seq: 3, Op: "leave", Time: now, Slot: 1, Registration: "KA-01-HH-1234"},
+			wantErr: false,
+		},
+		{name: "Remove another car",
+			record:  journalRecord{Seq: 3, Op: "leave", Time: now, Slot: 1, Registration: "KA-01-HH-9999"},
+			wantErr: true,
+		},
+		{name: "Initialize an initialized carpark",
+			record:  journalRecord{Seq: 3, Op: "init", Time: now, Floors: []int{3}},
+			wantErr: true,
+		},
+		{name: "Move a car",
+			record:  journalRecord{Seq: 3, Op: "move", Time: now, Slot: 1, To: 3},
+			wantErr: false,
+		},
+		{name: "Swap a car with an empty slot",
+			record:  journalRecord{Seq: 3, Op: "swap", Time: now, Slot: 1, To: 2},
+			wantErr: true,
+		},
+		{name: "Lower the highest slot",
+			record:  journalRecord{Seq: 3, Op: "lower_highest_slot", Time: now},
+			wantErr: false,
+		},
+		{name: "Expand the carpark",
+			record:  journalRecord{Seq: 3, Op: "expand", Time: now, Slots: 2},
+			wantErr: false,
+		},
+		{name: "Shrink the carpark",
+			record:  journalRecord{Seq: 3, Op: "shrink", Time: now, Slots: 2},
+			wantErr: false,
+		},
+		{name: "Set the class of a slot",
+			record:  journalRecord{Seq: 3, Op: "set_slot_class", Time: now, Slot: 2, Class: "van"},
+			wantErr: false,
+		},
+		{name: "Set an unknown class of a slot",
+			record:  journalRecord{Seq: 3, Op: "set_slot_class", Time: now, Slot: 2, Class: "tank"},
+			wantErr: true,
+		},
+		{name: "Close a slot",
+			record:  journalRecord{Seq: 3, Op: "close_slot", Time: now, Slot: 2},
+			wantErr: false,
+		},
+		{name: "Open a slot which is not closed",
+			record:  journalRecord{Seq: 3, Op: "open_slot", Time: now, Slot: 2},
+			wantErr: true,
+		},
+		{name: "Reserve a slot",
+			record:  journalRecord{Seq: 3, Op: "reserve", Time: now, Slot: 2, Registration: "KA-01-HH-2701", Until: &until},
+			wantErr: false,
+		},
+		{name: "Reserve an occupied slot",
+			record:  journalRecord{Seq: 3, Op: "reserve", Time: now, Slot: 1, Registration: "KA-01-HH-2701", Until: &until},
+			wantErr: true,
+		},
+		{name: "Reserve a slot without an expiry",
+			record:  journalRecord{Seq: 3, Op: "reserve", Time: now, Slot: 2, Registration: "KA-01-HH-2701"},
+			wantErr: true,
+		},
+		{name: "Cancel a missing reservation",
+			record:  journalRecord{Seq: 3, Op: "cancel_reservation", Time: now, Registration: "KA-01-HH-2701"},
+			wantErr: true,
+		},
+		{name: "Install a charger",
+			record:  journalRecord{Seq: 3, Op: "set_charger", Time: now, Slot: 1, Power: 7.4, Price: 30},
+			wantErr: false,
+		},
+		{name: "Start charging at a slot without a charger",
+			record:  journalRecord{Seq: 3, Op: "start_charge", Time: now, Slot: 1},
+			wantErr: true,
+		},
+		{name: "Stop charging a car which is not charging",
+			record:  journalRecord{Seq: 3, Op: "stop_charge", Time: now, Slot: 1},
+			wantErr: true,
+		},
+		{name: "Undo parking the last car parked",
+			record:  journalRecord{Seq: 3, Op: "unpark", Time: now, Slot: 1, Registration: "KA-01-HH-1234", Frontier: &journalFrontier{Highest: 0}},
+			wantErr: false,
+		},
+		{name: "Undo parking without the frontier",
+			record:  journalRecord{Seq: 3, Op: "unpark", Time: now, Slot: 1, Registration: "KA-01-HH-1234"},
+			wantErr: true,
+		},
+		{name: "Undo the leaving of a car which did not leave",
+			record:  journalRecord{Seq: 3, Op: "unleave", Time: now, Car: &snapshotCar{Slot: 2, Registration: "KA-01-HH-2701", Colour: "Blue", Class: "car", Entry: now, Ticket: "T000002"}},
+			wantErr: true,
+		},
+		{name: "Undo the move of a car which was not moved",
+			record:  journalRecord{Seq: 3, Op: "unmove", Time: now, Slot: 2, To: 1, Frontier: &journalFrontier{Highest: 1}},
+			wantErr: true,
+		},
+		{name: "Undo the swap of an empty slot",
+			record:  journalRecord{Seq: 3, Op: "unswap", Time: now, Slot: 1, To: 2},
+			wantErr: true,
+		},
+		{name: "Out of sequence",
+			record:  journalRecord{Seq: 4, Op: "leave", Time: now, Slot: 1, Registration: "KA-01-HH-1234"},
+			wantErr: true,
+		},
+		{name: "Unknown operation",
+			record:  journalRecord{Seq: 3, Op: "rename", Time: now},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carpark := &Carpark{}
+			records := []journalRecord{
+				{Seq: 1, Op: "init", Time: now, Floors: []int{3}},
+				{Seq: 2, Op: "park", Time: now, Car: &snapshotCar{Slot: 1, Registration: "KA-01-HH-1234", Colour: "White", Class: "car", Entry: now, Ticket: "T000001"}},
+			}
+			if err := carpark.replay(records); err != nil {
+				t.Fatalf("Carpark.replay() error = %v", err)
+			}
+			if err := carpark.replay([]journalRecord{tt.record}); (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.replay() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCarpark_recordFailure(t *testing.T) {
+	journal, _, err := openJournal(filepath.Join(t.TempDir(), "carpark.journal"))
+	if err != nil {
+		t.Fatalf("openJournal() error = %v", err)
+	}
+	carpark := &Carpark{journal: journal}
+	if err := carpark.init(3); err != nil {
+		t.Fatalf("Carpark.init() error = %v", err)
+	}
+	//Writes to a closed file fail, after which the journal refuses further records
+	journal.close()
+	if _, err := carpark.insertCar(&Car{registration: "KA-01-HH-1234", colour: "White"}); err == nil {
+		t.Errorf("Carpark.insertCar() error = %v, wantErr true", err)
+	}
+	if _, err := carpark.removeCar(1); err == nil || err != journal.err {
+		t.Errorf("Carpark.removeCar() error = %v, want %v", err, journal.err)
+	}
+	if carpark.journaled != 1 {
+		t.Errorf("Carpark.journaled = %v, want 1", carpark.journaled)
+	}
+}
@@ -2,15 +2,20 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"pretty"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 var inputInteractive io.Reader = os.Stdin
@@ -18,14 +23,20 @@ var outStream io.Writer = os.Stdout
 
 func main() {
 
+	//Command line flags
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	stateFile := flags.String("state", "", "file the carpark is restored from on startup and saved to on exit")
+	journalFile := flags.String("journal", "", "file every parked and leaving car is logged to, which is replayed on startup")
+	flags.Parse(os.Args[1:])
+
 	//Input file or interactive mode
-	ii := len(os.Args)
+	ii := flags.NArg()
 	var scanner *bufio.Scanner
 	switch {
-	case ii > 2:
+	case ii > 1:
 		log.Fatal("Unknown command line input")
-	case ii == 2:
-		inputFile, err := os.Open(os.Args[1])
+	case ii == 1:
+		inputFile, err := os.Open(flags.Arg(0))
 		if err != nil {
 			panic(err)
 		}
@@ -35,51 +46,417 @@ func main() {
 		scanner = bufio.NewScanner(inputInteractive)
 	}
 
-	//Create a carpark
+	//Create a carpark, restoring its state when a state file was saved before
 	var carpark = &Carpark{}
+	if *stateFile != "" {
+		restored, err := loadCarpark(*stateFile)
+		switch {
+		case err == nil:
+			carpark = restored
+		case !errors.Is(err, fs.ErrNotExist):
+			log.Fatal(err)
+		}
+	}
+
+	//Replay the journal written since the state was saved, and keep journaling
+	if *journalFile != "" {
+		journal, records, err := openJournal(*journalFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer journal.close()
+		if err := carpark.replay(records); err != nil {
+			log.Fatal(err)
+		}
+		carpark.journal = journal
+	}
 
 	//Operate the carpark
 	operateCarpark(carpark, scanner)
+
+	//Save the state of the carpark, after which the journal is no longer needed
+	if *stateFile != "" && carpark.initStatus() == nil {
+		if err := carpark.save(*stateFile); err != nil {
+			log.Fatal(err)
+		}
+		if carpark.journal != nil {
+			if err := carpark.journal.reset(); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
 }
 
 //operateCarpark reads input queries from console or text file and executes the command
 func operateCarpark(carpark *Carpark, scanner *bufio.Scanner) {
 	newlineStr := getNewlineStr()
 	exit := false
+	history := newUndoHistory(undoDepth)
+	carpark.tracking = true
+	defer func() { carpark.tracking = false }()
 	for !exit && scanner.Scan() {
 		input := scanner.Text()
 		input = strings.TrimRight(input, newlineStr)
 		s := parse(input)
 
 		switch {
-		case s[0] == "create_parking_lot" && len(s) == 2: //Initialize carpark
-			maxSlot, err := strconv.Atoi(s[1])
+		case s[0] == "create_parking_lot" && (len(s) == 2 || len(s) == 3): //Initialize carpark, optionally with an allocation strategy
+			floors, err := parseFloors(s[1])
 			if checkError(err) {
 				break
 			}
-			err = carpark.init(maxSlot)
-			if !checkError(err) {
-				fmt.Fprintf(outStream, "Created a parking lot with %v slots\n", maxSlot)
+			var strategy AllocationStrategy
+			if len(s) == 3 {
+				name, args, err := parseStrategyOption(s[2])
+				if checkError(err) {
+					break
+				}
+				strategy, err = carpark.newAllocationStrategy(name, args...)
+				if checkError(err) {
+					break
+				}
+			}
+			err = carpark.init(floors...)
+			if checkError(err) {
+				break
+			}
+			carpark.strategy = strategy
+			if len(floors) == 1 {
+				fmt.Fprintf(outStream, "Created a parking lot with %v slots\n", carpark.maxSlot)
+			} else {
+				fmt.Fprintf(outStream, "Created a parking lot with %v slots on %v floors\n", carpark.maxSlot, len(floors))
 			}
 
-		case s[0] == "park" && len(s) == 3: //Park a new car
+		case s[0] == "park" && len(s) >= 3: //Park a new car, optionally of a given vehicle class, through a given entrance, or with a given waiting list priority
 			car := Car{
 				registration: s[1],
 				colour:       s[2],
 			}
+			priority, err := parseParkOptions(&car, s[3:])
+			if checkError(err) {
+				break
+			}
+			//Serve the waiting list first from slots released by expired reservations
+			printAdmitted(carpark)
 			slotNo, err := carpark.insertCar(&car)
+			if errors.Is(err, errFull) && carpark.waiting != nil {
+				//Put the car on the waiting list instead of turning it away
+				position, err := carpark.enqueue(&car, priority)
+				if !checkError(err) {
+					fmt.Fprintf(outStream, "Car %v is number %v on the waiting list\n", car.registration, position)
+				}
+				break
+			}
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Allocated slot number: %v (ticket %v)\n", carpark.slotLabel(slotNo), car.ticket)
+			}
+
+		case s[0] == "reserve" && (len(s) == 4 || len(s) == 5) && s[len(s)-2] == "until": //Reserve a slot for a car until a given time, optionally choosing the slot
+			until, err := time.ParseInLocation(timeLayout, s[len(s)-1], time.Local)
+			if checkError(err) {
+				break
+			}
+			slotNo := 0
+			if len(s) == 5 {
+				slotNo, err = carpark.parseSlot(s[2])
+				if checkError(err) {
+					break
+				}
+			}
+			slotNo, err = carpark.reserve(s[1], slotNo, until)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Reserved slot number %v for %v until %v\n", carpark.slotLabel(slotNo), s[1], until.Format(timeLayout))
+			}
+
+		case s[0] == "cancel_reservation" && len(s) == 2: //Cancel the reservation of a car
+			slotNo, err := carpark.cancelReservation(s[1])
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Slot number %v is free\n", carpark.slotLabel(slotNo))
+				printAdmitted(carpark)
+			}
+
+		case s[0] == "set_waiting_list" && len(s) == 2: //Set the order of the waiting list, or turn cars away from a full carpark
+			err := carpark.setWaitingList(s[1])
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Waiting list is %v\n", s[1])
+			}
+
+		case s[0] == "waiting_list" && len(s) == 1: //Retrieve the cars on the waiting list
+			printWaitingList(carpark.waitingCars())
+
+		case s[0] == "cancel_waiting" && len(s) == 2: //Remove a car from the waiting list
+			car, err := carpark.cancelWaiting(s[1])
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Car %v left the waiting list\n", car.registration)
+			}
+
+		case s[0] == "leave" && (len(s) == 2 || len(s) == 3): //Remove a parked car, optionally verifying its ticket
+			slotNo, err := carpark.parseSlot(s[1])
+			if checkError(err) {
+				break
+			}
+			if len(s) == 3 {
+				err = carpark.verifyTicket(slotNo, s[2])
+				if checkError(err) {
+					break
+				}
+			}
+			car, err := carpark.removeCar(slotNo)
+			if !checkError(err) {
+				printLeave(carpark, car)
+				printAdmitted(carpark)
+			}
+
+		case s[0] == "leave_ticket" && len(s) == 2: //Remove the parked car holding the given ticket
+			car, err := carpark.removeCarWithTicket(s[1])
+			if !checkError(err) {
+				printLeave(carpark, car)
+				printAdmitted(carpark)
+			}
+
+		case (s[0] == "move" || s[0] == "swap") && len(s) == 3: //Move a car to an empty slot, or swap the cars in two slots
+			from, err := carpark.parseSlot(s[1])
+			if checkError(err) {
+				break
+			}
+			to, err := carpark.parseSlot(s[2])
+			if checkError(err) {
+				break
+			}
+			if s[0] == "swap" {
+				err = carpark.swapCars(from, to)
+				if !checkError(err) {
+					fmt.Fprintf(outStream, "Swapped slot numbers %v and %v\n", carpark.slotLabel(from), carpark.slotLabel(to))
+				}
+				break
+			}
+			car, err := carpark.moveCar(from, to)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Moved %v from slot number %v to slot number %v\n", car.registration, carpark.slotLabel(from), carpark.slotLabel(to))
+			}
+
+		case s[0] == "compact" && len(s) == 1: //Plan the moves packing cars toward the entry
+			if err := carpark.initStatus(); checkError(err) {
+				break
+			}
+			plan := carpark.compactPlan()
+			if len(plan) == 0 {
+				fmt.Fprintln(outStream, "Parking lot is compact")
+			}
+			for _, step := range plan {
+				fmt.Fprintf(outStream, "Move %v from slot number %v to slot number %v\n", carpark.Map[step.from].registration, carpark.slotLabel(step.from), carpark.slotLabel(step.to))
+			}
+
+		case s[0] == "compact" && len(s) == 2 && s[1] == "apply": //Pack cars toward the entry
+			moved, err := carpark.compact()
+			for _, step := range moved {
+				fmt.Fprintf(outStream, "Moved %v from slot number %v to slot number %v\n", carpark.Map[step.to].registration, carpark.slotLabel(step.from), carpark.slotLabel(step.to))
+			}
+			if !checkError(err) && len(moved) == 0 {
+				fmt.Fprintln(outStream, "Parking lot is compact")
+			}
+
+		case s[0] == "quote" && len(s) == 2: //Price the current stay of the car with given registration number
+			fee, err := carpark.quote(s[1])
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Amount due: %v\n", formatAmount(fee))
+			}
+
+		case s[0] == "load_tariff" && len(s) == 2: //Load the parking charges from a file
+			tariff, err := loadTariff(s[1])
+			if checkError(err) {
+				break
+			}
+			carpark.tariff = tariff
+			fmt.Fprintf(outStream, "Loaded tariff from %v\n", s[1])
+
+		case s[0] == "expand_parking_lot" && len(s) == 2: //Add slots to the carpark
+			slots, err := strconv.Atoi(s[1])
+			if checkError(err) {
+				break
+			}
+			err = carpark.expand(slots)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Expanded parking lot to %v slots\n", carpark.maxSlot)
+				printAdmitted(carpark)
+			}
+
+		case s[0] == "shrink_parking_lot" && len(s) == 2: //Remove empty slots from the carpark
+			slots, err := strconv.Atoi(s[1])
+			if checkError(err) {
+				break
+			}
+			err = carpark.shrink(slots)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Shrunk parking lot to %v slots\n", carpark.maxSlot)
+			}
+
+		case s[0] == "close_slot" && len(s) == 2: //Take an empty slot out of service
+			slotNo, err := carpark.parseSlot(s[1])
+			if checkError(err) {
+				break
+			}
+			err = carpark.closeSlot(slotNo)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Slot number %v is closed\n", carpark.slotLabel(slotNo))
+			}
+
+		case s[0] == "open_slot" && len(s) == 2: //Put a closed slot back into service
+			slotNo, err := carpark.parseSlot(s[1])
+			if checkError(err) {
+				break
+			}
+			err = carpark.openSlot(slotNo)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Slot number %v is open\n", carpark.slotLabel(slotNo))
+				printAdmitted(carpark)
+			}
+
+		case s[0] == "set_slot_class" && len(s) >= 3: //Set the class of vehicle which empty slots are built for
+			class, err := parseVehicleClass(s[1])
+			if checkError(err) {
+				break
+			}
+			for _, id := range s[2:] {
+				slotNo, err := carpark.parseSlot(id)
+				if checkError(err) {
+					continue
+				}
+				err = carpark.setSlotClass(slotNo, class)
+				if !checkError(err) {
+					fmt.Fprintf(outStream, "Slot number %v is a %v slot\n", carpark.slotLabel(slotNo), class)
+				}
+			}
+
+		case s[0] == "set_allocation_strategy" && len(s) >= 2: //Set how the slot allocated to each car is chosen
+			strategy, err := carpark.newAllocationStrategy(s[1], s[2:]...)
+			if checkError(err) {
+				break
+			}
+			if err = carpark.initStatus(); checkError(err) {
+				break
+			}
+			carpark.strategy = strategy
+			fmt.Fprintf(outStream, "Allocation strategy is %v\n", strings.Join(s[1:], " "))
+
+		case s[0] == "add_entrance" && len(s) == 3: //Add a named entrance next to a slot
+			slotNo, err := carpark.parseSlot(s[2])
+			if checkError(err) {
+				break
+			}
+			err = carpark.addEntrance(s[1], slotNo)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Added entrance %v next to slot number %v\n", s[1], carpark.slotLabel(slotNo))
+			}
+
+		case s[0] == "add_exit" && len(s) == 3: //Add a named exit next to a slot
+			slotNo, err := carpark.parseSlot(s[2])
+			if checkError(err) {
+				break
+			}
+			err = carpark.addExit(s[1], slotNo)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Added exit %v next to slot number %v\n", s[1], carpark.slotLabel(slotNo))
+			}
+
+		case s[0] == "set_distance" && len(s) == 4: //Set the distance from an entrance or exit to a slot
+			slotNo, err := carpark.parseSlot(s[2])
+			if checkError(err) {
+				break
+			}
+			distance, err := strconv.Atoi(s[3])
+			if checkError(err) {
+				break
+			}
+			err = carpark.setDistance(s[1], slotNo, distance)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Distance from %v to slot number %v is %v\n", s[1], carpark.slotLabel(slotNo), distance)
+			}
+
+		case (s[0] == "set_slot_attribute" || s[0] == "clear_slot_attribute") && len(s) >= 3: //Add or remove attributes of slots
+			attributes, err := parseSlotAttributes(s[1])
+			if checkError(err) {
+				break
+			}
+			for _, id := range s[2:] {
+				slotNo, err := carpark.parseSlot(id)
+				if checkError(err) {
+					continue
+				}
+				err = carpark.setSlotAttributes(slotNo, attributes, s[0] == "set_slot_attribute")
+				if !checkError(err) {
+					fmt.Fprintf(outStream, "Slot number %v has attributes %v\n", carpark.slotLabel(slotNo), formatAttributes(carpark.attributes[slotNo]))
+				}
+			}
+
+		case s[0] == "set_attribute_rule" && len(s) == 3: //Set whether slots with attributes are given to cars not asking for them as a last resort or never
+			attributes, err := parseSlotAttributes(s[1])
+			if checkError(err) {
+				break
+			}
+			err = carpark.setAttributeRule(attributes, s[2])
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Slots with attributes %v are given to other cars %v\n", attributes, strings.Replace(s[2], "_", " ", -1))
+			}
+
+		case s[0] == "set_charger" && len(s) == 4: //Install an EV charger of a given power in kW and price in cents per kWh at a slot
+			slotNo, err := carpark.parseSlot(s[1])
+			if checkError(err) {
+				break
+			}
+			power, err := strconv.ParseFloat(s[2], 64)
+			if checkError(err) {
+				break
+			}
+			price, err := strconv.Atoi(s[3])
+			if checkError(err) {
+				break
+			}
+			err = carpark.setCharger(slotNo, power, price)
 			if !checkError(err) {
-				fmt.Fprintf(outStream, "Allocated slot number: %v\n", slotNo)
+				fmt.Fprintf(outStream, "Slot number %v has a %v kW charger at %v per kWh\n", carpark.slotLabel(slotNo), power, formatAmount(price))
 			}
 
-		case s[0] == "leave" && len(s) == 2: //Remove a parked car
-			slotNo, err := strconv.Atoi(s[1])
+		case s[0] == "start_charge" && len(s) == 2: //Start charging the car in a slot
+			slotNo, err := carpark.parseSlot(s[1])
+			if checkError(err) {
+				break
+			}
+			car, err := carpark.startCharge(slotNo)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Charging %v at slot number %v\n", car.registration, carpark.slotLabel(slotNo))
+			}
+
+		case s[0] == "stop_charge" && len(s) == 2: //Stop charging the car in a slot
+			slotNo, err := carpark.parseSlot(s[1])
+			if checkError(err) {
+				break
+			}
+			session, err := carpark.stopCharge(slotNo)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Charged %.2f kWh in %v at slot number %v\n", session.energy, formatDuration(session.duration), carpark.slotLabel(slotNo))
+				fmt.Fprintf(outStream, "Amount due: %v\n", formatAmount(session.cost))
+			}
+
+		case s[0] == "charger_report" && len(s) == 1: //Retrieve the usage of each charger
+			printChargerReport(carpark)
+
+		case s[0] == "set_registration_format" && (len(s) == 2 || len(s) == 3): //Set the format of registration numbers accepted by the carpark
+			pattern := ""
+			if len(s) == 3 {
+				pattern = s[2]
+			}
+			validator, err := newRegistrationValidator(s[1], pattern)
 			if checkError(err) {
 				break
 			}
-			err = carpark.removeCar(slotNo)
+			carpark.validator = validator
+			fmt.Fprintf(outStream, "Registration number format is %v\n", s[1])
+
+		case s[0] == "colour_synonym" && len(s) == 3: //Treat a colour as a synonym of another colour
+			err := carpark.addColourSynonym(s[1], s[2])
 			if !checkError(err) {
-				fmt.Fprintf(outStream, "Slot number %v is free\n", slotNo)
+				fmt.Fprintf(outStream, "Colour %v is a synonym of %v\n", s[1], s[2])
 			}
 
 		case s[0] == "registration_numbers_for_cars_with_colour" && len(s) == 2: //Return registration numbers with given car colour
@@ -97,7 +474,11 @@ func operateCarpark(carpark *Carpark, scanner *bufio.Scanner) {
 			if checkError(err) {
 				break
 			}
-			err = pretty.Printer(slots, outStream)
+			labels := make([]string, len(slots))
+			for i, slotNo := range slots {
+				labels[i] = carpark.slotLabel(slotNo)
+			}
+			err = pretty.Printer(labels, outStream)
 			if err != nil {
 				panic(err.Error())
 			}
@@ -105,18 +486,74 @@ func operateCarpark(carpark *Carpark, scanner *bufio.Scanner) {
 		case s[0] == "slot_number_for_registration_number" && len(s) == 2: //Return slot numbers with given car registration number
 			slotNo, err := carpark.getCarWithRegistrationNo(s[1])
 			if !checkError(err) {
-				fmt.Fprintln(outStream, slotNo)
+				fmt.Fprintln(outStream, carpark.slotLabel(slotNo))
+			}
+
+		case s[0] == "duration_for_registration_number" && len(s) == 2: //Return how long the car with given registration number has been parked
+			duration, err := carpark.getDurationWithRegistrationNo(s[1])
+			if !checkError(err) {
+				fmt.Fprintln(outStream, formatDuration(duration))
+			}
+
+		case s[0] == "time" && len(s) == 2: //Set the carpark clock
+			now, err := time.ParseInLocation(timeLayout, s[1], time.Local)
+			if checkError(err) {
+				break
+			}
+			err = carpark.setTime(now)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Time is %v\n", now.Format(timeLayout))
+				printAdmitted(carpark)
+			}
+
+		case s[0] == "save" && len(s) == 2: //Save the state of the carpark to a file
+			err := carpark.save(s[1])
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Saved parking lot to %v\n", s[1])
+			}
+
+		case s[0] == "load" && len(s) == 2: //Replace the state of the carpark with the state saved in a file
+			if carpark.journal != nil {
+				checkError(errors.New("Cannot load a parking lot while journaling"))
+				break
+			}
+			restored, err := loadCarpark(s[1])
+			if !checkError(err) {
+				restored.tracking = carpark.tracking
+				*carpark = *restored
+				fmt.Fprintf(outStream, "Loaded parking lot from %v\n", s[1])
 			}
 
 		case s[0] == "status" && len(s) == 1: //Retrieve cars parked in carpark
-			cars := carpark.getStatus()
-			var w = tabwriter.NewWriter(outStream, 0, 0, 4, ' ', 0)
-			fmt.Fprintln(w, "Slot No.\tRegistration No\tColour")
-			for _, car := range cars {
-				s := fmt.Sprintf("%v\t%s\t%s", car.slot, car.registration, car.colour)
-				fmt.Fprintln(w, s)
+			printStatus(carpark, carpark.getStatus(), carpark.now())
+
+		case s[0] == "status_at" && len(s) == 2: //Retrieve cars parked in carpark at a past time
+			at, err := time.ParseInLocation(timeLayout, s[1], time.Local)
+			if checkError(err) {
+				break
+			}
+			cars, err := carpark.statusAt(at)
+			if !checkError(err) {
+				printStatus(carpark, cars, at)
+			}
+
+		case s[0] == "history_for_registration_number" && len(s) == 2: //Retrieve the visits of the car with given registration number
+			visits, err := carpark.history(s[1])
+			if !checkError(err) {
+				printHistory(carpark, visits)
+			}
+
+		case s[0] == "undo" && len(s) == 1: //Revert the last command parking, removing or moving cars
+			cmd, err := history.undo(carpark)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Undid %v\n", cmd.input)
+			}
+
+		case s[0] == "redo" && len(s) == 1: //Repeat the last command undone
+			cmd, err := history.redo(carpark)
+			if !checkError(err) {
+				fmt.Fprintf(outStream, "Redid %v\n", cmd.input)
 			}
-			w.Flush()
 
 		case s[0] == "exit" && len(s) == 1: //End carpark operation
 			exit = true
@@ -124,9 +561,207 @@ func operateCarpark(carpark *Carpark, scanner *bufio.Scanner) {
 		default: //Default option
 			fmt.Fprintln(outStream, "Unknown input command")
 		}
+
+		//Keep the commands which can be undone, and forget them once the carpark is changed otherwise
+		changes := carpark.takeChanges()
+		switch {
+		case undoableCommands[s[0]]:
+			if len(changes) > 0 {
+				history.push(&command{input: input, changes: changes})
+			}
+		case irreversibleCommands[s[0]] || len(changes) > 0:
+			history.clear()
+		}
+
+		//Stop once the journal cannot be written, so that restarting replays every acknowledged command
+		if carpark.journal != nil && carpark.journal.err != nil {
+			log.Fatal(carpark.journal.err)
+		}
+	}
+}
+
+//printLeave prints the slot freed by a car leaving the carpark, and the amount due if parking is charged
+func printLeave(carpark *Carpark, car *Car) {
+	fmt.Fprintf(outStream, "Slot number %v is free\n", carpark.slotLabel(car.slot))
+	if carpark.tariff != nil {
+		fee, _ := carpark.fee(car)
+		fmt.Fprintf(outStream, "Amount due: %v\n", formatAmount(fee))
+	}
+	if car.energy > 0 {
+		fmt.Fprintf(outStream, "Charged %.2f kWh in %v, amount due: %v\n", car.energy, formatDuration(car.chargeTime), formatAmount(car.chargeCost))
+	}
+}
+
+//printChargerReport prints the sessions, energy delivered and utilisation of each charger in slot order
+func printChargerReport(carpark *Carpark) {
+	var slots []int
+	for slotNo := range carpark.chargers {
+		slots = append(slots, slotNo)
+	}
+	sort.Ints(slots)
+	var w = tabwriter.NewWriter(outStream, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "Slot No.\tPower\tSessions\tEnergy\tCharging\tUtilisation")
+	for _, slotNo := range slots {
+		point := carpark.chargers[slotNo]
+		fmt.Fprintf(w, "%v\t%v kW\t%v\t%.2f kWh\t%v\t%.0f%%\n", carpark.slotLabel(slotNo), point.power, point.sessions,
+			point.energy, formatDuration(carpark.chargerBusy(slotNo)), 100*carpark.chargerUtilisation(slotNo))
+	}
+	w.Flush()
+}
+
+//printAdmitted parks the cars on the waiting list which fit the empty slots, and prints the slots allocated to them
+func printAdmitted(carpark *Carpark) {
+	for _, car := range carpark.admitWaiting() {
+		fmt.Fprintf(outStream, "Allocated slot number: %v to %v from the waiting list (ticket %v)\n", carpark.slotLabel(car.slot), car.registration, car.ticket)
 	}
 }
 
+//printWaitingList prints the cars on the waiting list in the order they are served
+func printWaitingList(cars []*waitingCar) {
+	var w = tabwriter.NewWriter(outStream, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "No.\tRegistration No\tColour\tPriority")
+	for i, waiting := range cars {
+		fmt.Fprintf(w, "%v\t%s\t%s\t%v\n", i+1, waiting.car.registration, waiting.car.colour, waiting.priority)
+	}
+	w.Flush()
+}
+
+//printStatus prints the cars parked in the carpark and how long they were parked until the given time, grouped by
+//floor in multi-level carparks, with the attributes of their slots when any slot has attributes
+func printStatus(carpark *Carpark, cars []*Car, now time.Time) {
+	var w = tabwriter.NewWriter(outStream, 0, 0, 4, ' ', 0)
+	header := "Slot No.\tRegistration No\tColour\tDuration"
+	if len(carpark.attributes) > 0 {
+		header += "\tAttributes"
+	}
+	if len(carpark.floors) <= 1 {
+		fmt.Fprintln(w, header)
+	}
+	floor := 0
+	for _, car := range cars {
+		if carFloor, _ := carpark.floorOf(car.slot); len(carpark.floors) > 1 && carFloor != floor {
+			floor = carFloor
+			fmt.Fprintf(w, "Floor %v\n", floor)
+			fmt.Fprintln(w, header)
+		}
+		duration := formatDuration(car.duration(now))
+		s := fmt.Sprintf("%v\t%s\t%s\t%s", carpark.slotLabel(car.slot), car.registration, car.colour, duration)
+		if len(carpark.attributes) > 0 {
+			s += "\t" + formatAttributes(carpark.attributes[car.slot])
+		}
+		fmt.Fprintln(w, s)
+	}
+	w.Flush()
+}
+
+//printHistory prints the visits of a car in order of entry, with the slots it was moved between
+func printHistory(carpark *Carpark, visits []*visit) {
+	var w = tabwriter.NewWriter(outStream, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "Ticket\tSlot No.\tEntry\tExit\tDuration")
+	for _, visit := range visits {
+		labels := make([]string, len(visit.slots))
+		for i, slotNo := range visit.slots {
+			labels[i] = carpark.slotLabel(slotNo)
+		}
+		exit, end := "-", carpark.now()
+		if !visit.exit.IsZero() {
+			exit, end = visit.exit.Format(timeLayout), visit.exit
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", visit.ticket, strings.Join(labels, ", "), visit.entry.Format(timeLayout), exit, formatDuration(end.Sub(visit.entry)))
+	}
+	w.Flush()
+}
+
+//formatAttributes prints the attributes of a slot, or a dash when the slot has none
+func formatAttributes(attributes SlotAttributes) string {
+	if attributes == 0 {
+		return "-"
+	}
+	return attributes.String()
+}
+
+//parseFloors reads the carpark size given as a number of slots, "<floors>x<slots>", or a comma separated list of slots per floor
+func parseFloors(size string) ([]int, error) {
+	var floors []int
+	if parts := strings.Split(size, "x"); len(parts) == 2 {
+		count, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		slots, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		//Check the size before building the floors, as a huge count would exhaust memory
+		if count <= 0 || slots <= 0 || count > maxParkingLotSize/slots {
+			return nil, errors.New("Invalid parking lot size")
+		}
+		for i := 0; i < count; i++ {
+			floors = append(floors, slots)
+		}
+		return floors, nil
+	}
+	for _, part := range strings.Split(size, ",") {
+		slots, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		floors = append(floors, slots)
+	}
+	return floors, nil
+}
+
+//parseParkOptions reads the options of a car being parked, which are its vehicle class, "gate=<entrance>",
+//"require=<attributes>" and "prefer=<attributes>" of its slot, and "priority=<priority>" on the waiting list, and
+//returns the priority
+func parseParkOptions(car *Car, options []string) (int, error) {
+	priority := 0
+	for _, option := range options {
+		switch {
+		case strings.HasPrefix(option, "gate="):
+			car.gate = strings.TrimPrefix(option, "gate=")
+		case strings.HasPrefix(option, "require="):
+			attributes, err := parseSlotAttributes(strings.TrimPrefix(option, "require="))
+			if err != nil {
+				return 0, err
+			}
+			car.required |= attributes
+		case strings.HasPrefix(option, "prefer="):
+			attributes, err := parseSlotAttributes(strings.TrimPrefix(option, "prefer="))
+			if err != nil {
+				return 0, err
+			}
+			car.preferred |= attributes
+		case strings.HasPrefix(option, "priority="):
+			var err error
+			priority, err = strconv.Atoi(strings.TrimPrefix(option, "priority="))
+			if err != nil {
+				return 0, errors.New("Invalid priority")
+			}
+		default:
+			class, err := parseVehicleClass(option)
+			if err != nil {
+				return 0, err
+			}
+			car.class = class
+		}
+	}
+	return priority, nil
+}
+
+//parseStrategyOption reads the allocation strategy given to create_parking_lot as "strategy=<name>[:<argument>]",
+//which cannot be the exit strategy since a new carpark has no exits
+func parseStrategyOption(option string) (string, []string, error) {
+	if !strings.HasPrefix(option, "strategy=") {
+		return "", nil, fmt.Errorf("Unknown option %v", option)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(option, "strategy="), ":", 2)
+	if parts[0] == "exit" {
+		return "", nil, errors.New("Allocation strategy exit needs an exit added after creating the parking lot")
+	}
+	return parts[0], parts[1:], nil
+}
+
 //getNewlineStr identifies operating system and returns newline character used
 func getNewlineStr() string {
 	if runtime.GOOS == "windows" {
@@ -135,6 +770,16 @@ func getNewlineStr() string {
 	return "\n"
 }
 
+//undoableCommands are the commands whose changes to the carpark can be undone
+var undoableCommands = map[string]bool{"park": true, "leave": true, "leave_ticket": true, "move": true, "swap": true}
+
+//irreversibleCommands are the commands changing the slots, cars or clock of the carpark in ways which undo does not revert
+var irreversibleCommands = map[string]bool{
+	"create_parking_lot": true, "reserve": true, "cancel_reservation": true, "set_waiting_list": true, "cancel_waiting": true,
+	"expand_parking_lot": true, "shrink_parking_lot": true, "close_slot": true, "open_slot": true, "set_slot_class": true,
+	"set_charger": true, "start_charge": true, "stop_charge": true, "time": true, "load": true,
+}
+
 func parse(input string) []string {
 	s := strings.Split(input, " ")
 	return s
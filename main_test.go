@@ -1,28 +1,33 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
 func wantOut() string {
 	out := `Created a parking lot with 6 slots
-Allocated slot number: 1
-Allocated slot number: 2
-Allocated slot number: 3
-Allocated slot number: 4
-Allocated slot number: 5
-Allocated slot number: 6
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Allocated slot number: 3 (ticket T000003)
+Allocated slot number: 4 (ticket T000004)
+Allocated slot number: 5 (ticket T000005)
+Allocated slot number: 6 (ticket T000006)
 Slot number 4 is free
-Slot No.    Registration No    Colour
-1           KA-01-HH-1234      White
-2           KA-01-HH-9999      White
-3           KA-01-BB-0001      Black
-5           KA-01-HH-2701      Blue
-6           KA-01-HH-3141      Black
-Allocated slot number: 4
+Slot No.    Registration No    Colour    Duration
+1           KA-01-HH-1234      White     0h00m
+2           KA-01-HH-9999      White     0h00m
+3           KA-01-BB-0001      Black     0h00m
+5           KA-01-HH-2701      Blue      0h00m
+6           KA-01-HH-3141      Black     0h00m
+Allocated slot number: 4 (ticket T000007)
 Sorry, parking lot is full
 KA-01-HH-1234, KA-01-HH-9999, KA-01-P-333
 1, 2, 4
@@ -83,3 +88,852 @@ func Test_main(t *testing.T) {
 		gotBuf.Reset()
 	}
 }
+
+func Test_operateCarpark(t *testing.T) {
+	//Save old settings before rewriting settings
+	oldOutStream := outStream
+	defer func() { outStream = oldOutStream }()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Multi-level carpark",
+			input: `create_parking_lot 2x2
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White
+park KA-01-BB-0001 Black
+leave 1-2
+status
+slot_numbers_for_cars_with_colour White
+slot_number_for_registration_number KA-01-BB-0001
+leave 3-1`,
+			want: `Created a parking lot with 4 slots on 2 floors
+Allocated slot number: 1-1 (ticket T000001)
+Allocated slot number: 1-2 (ticket T000002)
+Allocated slot number: 2-1 (ticket T000003)
+Slot number 1-2 is free
+Floor 1
+Slot No.    Registration No    Colour    Duration
+1-1         KA-01-HH-1234      White     0h00m
+Floor 2
+Slot No.    Registration No    Colour    Duration
+2-1         KA-01-BB-0001      Black     0h00m
+1-1
+2-1
+Invalid slot number
+`,
+		},
+		{name: "Per-floor capacity list",
+			input: `create_parking_lot 1,2
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White
+slot_number_for_registration_number KA-01-HH-9999`,
+			want: `Created a parking lot with 3 slots on 2 floors
+Allocated slot number: 1-1 (ticket T000001)
+Allocated slot number: 2-1 (ticket T000002)
+2-1
+`,
+		},
+		{name: "Vehicle classes",
+			input: `create_parking_lot 4
+set_slot_class van 3
+set_slot_class lorry 4
+park KA-01-HH-1234 White van
+park KA-01-HH-9999 White bus
+park KA-01-HH-2701 Blue motorcycle
+park KA-01-HH-3141 Black
+park KA-01-HH-7777 Red
+park KA-01-P-333 White van
+park DL-12-AA-9999 White`,
+			want: `Created a parking lot with 4 slots
+Slot number 3 is a van slot
+Unknown vehicle class lorry
+Allocated slot number: 3 (ticket T000001)
+Sorry, parking lot is full for class bus
+Allocated slot number: 1 (ticket T000002)
+Allocated slot number: 2 (ticket T000003)
+Allocated slot number: 4 (ticket T000004)
+Sorry, parking lot is full
+Sorry, parking lot is full
+`,
+		},
+		{name: "Past time",
+			input: `create_parking_lot 2
+time 2020-01-01T08:00
+park KA-01-HH-1234 White
+time 2019-12-31T08:00`,
+			want: `Created a parking lot with 2 slots
+Time is 2020-01-01T08:00
+Allocated slot number: 1 (ticket T000001)
+Time cannot be set backwards
+`,
+		},
+		{name: "Past time after parking by the system clock",
+			input: `create_parking_lot 2
+park KA-01-HH-1234 White
+time 2020-01-01T08:00`,
+			want: `Created a parking lot with 2 slots
+Allocated slot number: 1 (ticket T000001)
+Time cannot be set before the last car parked, moved or left
+`,
+		},
+		{name: "Parking duration",
+			input: `create_parking_lot 6
+time 2026-10-18T08:00
+park KA-01-HH-1234 White
+time 2026-10-18T09:30
+park KA-01-HH-9999 White
+time 2026-10-19T10:45
+duration_for_registration_number KA-01-HH-1234
+duration_for_registration_number MH-04-AY-1111
+time 2026-10-18T08:00
+status`,
+			want: `Created a parking lot with 6 slots
+Time is 2026-10-18T08:00
+Allocated slot number: 1 (ticket T000001)
+Time is 2026-10-18T09:30
+Allocated slot number: 2 (ticket T000002)
+Time is 2026-10-19T10:45
+26h45m
+Not found
+Time cannot be set backwards
+Slot No.    Registration No    Colour    Duration
+1           KA-01-HH-1234      White     26h45m
+2           KA-01-HH-9999      White     25h15m
+`,
+		},
+		{name: "Parking fees",
+			input: `create_parking_lot 6
+quote KA-01-HH-1234
+load_tariff missing.json
+load_tariff tariff.json
+time 2026-10-18T08:00
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White motorcycle
+time 2026-10-18T10:30
+quote KA-01-HH-1234
+leave 1
+leave 2
+quote KA-01-HH-1234`,
+			want: `Created a parking lot with 6 slots
+Not found
+open missing.json: no such file or directory
+Loaded tariff from tariff.json
+Time is 2026-10-18T08:00
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Time is 2026-10-18T10:30
+Amount due: 7.00
+Slot number 1 is free
+Amount due: 7.00
+Slot number 2 is free
+Amount due: 1.50
+Not found
+`,
+		},
+		{name: "Tickets",
+			input: `create_parking_lot 6
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White
+leave 1 T000002
+leave 1 T000001
+leave_ticket T000001
+leave_ticket T000002
+park KA-01-BB-0001 Black`,
+			want: `Created a parking lot with 6 slots
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Ticket does not match the car in the slot
+Slot number 1 is free
+Invalid ticket
+Slot number 2 is free
+Allocated slot number: 1 (ticket T000003)
+`,
+		},
+		{name: "Duplicate registration",
+			input: `create_parking_lot 6
+park KA-01-HH-1234 White
+park KA-01-HH-1234 Black
+leave 1
+park KA-01-HH-1234 Black
+slot_number_for_registration_number KA-01-HH-1234`,
+			want: `Created a parking lot with 6 slots
+Allocated slot number: 1 (ticket T000001)
+Car KA-01-HH-1234 is already parked
+Slot number 1 is free
+Allocated slot number: 1 (ticket T000002)
+1
+`,
+		},
+		{name: "Normalized colours and registrations",
+			input: `create_parking_lot 6
+park KA-01-HH-1234 White
+park KA-01-HH-9999 Gray
+park KA01HH1234 white
+park KA-01-HH-2701 Gray
+colour_synonym Cream White
+park KA-01-HH-3141 Cream
+registration_numbers_for_cars_with_colour WHITE
+slot_numbers_for_cars_with_colour grey
+slot_number_for_registration_number ka01hh2701
+status`,
+			want: `Created a parking lot with 6 slots
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Car KA01HH1234 is already parked
+Allocated slot number: 3 (ticket T000003)
+Colour Cream is a synonym of White
+Allocated slot number: 4 (ticket T000004)
+KA-01-HH-1234, KA-01-HH-3141
+2, 3
+3
+Slot No.    Registration No    Colour    Duration
+1           KA-01-HH-1234      White     0h00m
+2           KA-01-HH-9999      Gray      0h00m
+3           KA-01-HH-2701      Gray      0h00m
+4           KA-01-HH-3141      Cream     0h00m
+`,
+		},
+		{name: "Registration number formats",
+			input: `create_parking_lot 6
+set_registration_format mars
+set_registration_format india
+park KA-01-HH-1234 White
+park MH-04-AY- White
+set_registration_format uk
+park AB12-CDE Blue
+park KA-01-HH-9999 White
+set_registration_format regex ^[0-9]+$
+park 12345 Red
+set_registration_format any
+park KA-01-HH-9999 White`,
+			want: `Created a parking lot with 6 slots
+Unknown registration number format mars
+Registration number format is india
+Allocated slot number: 1 (ticket T000001)
+Invalid india registration number MH-04-AY-
+Registration number format is uk
+Allocated slot number: 2 (ticket T000002)
+Invalid uk registration number KA-01-HH-9999
+Registration number format is regex
+Allocated slot number: 3 (ticket T000003)
+Registration number format is any
+Allocated slot number: 4 (ticket T000004)
+`,
+		},
+		{name: "Resize carpark",
+			input: `create_parking_lot 2
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White
+park KA-01-BB-0001 Black
+expand_parking_lot 2
+park KA-01-BB-0001 Black
+leave 2
+shrink_parking_lot 2
+leave 3
+shrink_parking_lot 2
+park KA-01-HH-7777 Red
+park KA-01-HH-2701 Blue
+park KA-01-HH-3141 Black`,
+			want: `Created a parking lot with 2 slots
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Sorry, parking lot is full
+Expanded parking lot to 4 slots
+Allocated slot number: 3 (ticket T000003)
+Slot number 2 is free
+Cannot shrink parking lot, occupied slots: 3
+Slot number 3 is free
+Shrunk parking lot to 2 slots
+Allocated slot number: 2 (ticket T000004)
+Sorry, parking lot is full
+Sorry, parking lot is full
+`,
+		},
+		{name: "Closed slots",
+			input: `create_parking_lot 3
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White
+close_slot 2
+leave 2
+close_slot 2
+close_slot 3
+park KA-01-BB-0001 Black
+open_slot 3
+park KA-01-BB-0001 Black
+open_slot 2
+open_slot 2
+park KA-01-HH-7777 Red`,
+			want: `Created a parking lot with 3 slots
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Slot is occupied
+Slot number 2 is free
+Slot number 2 is closed
+Slot number 3 is closed
+Sorry, parking lot is full
+Slot number 3 is open
+Allocated slot number: 3 (ticket T000003)
+Slot number 2 is open
+Slot is not closed
+Allocated slot number: 2 (ticket T000004)
+`,
+		},
+		{name: "Allocation strategies",
+			input: `create_parking_lot 2x3 strategy=fill_by_floor
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White
+leave 1-1
+set_allocation_strategy exit
+add_exit B 2-3
+set_allocation_strategy exit B
+park KA-01-BB-0001 Black
+set_allocation_strategy fill_by_floor
+park KA-01-HH-7777 Red
+set_allocation_strategy spread
+park KA-01-HH-2701 Blue
+create_parking_lot 3 strategy=exit:B
+create_parking_lot 3 strategy=farthest`,
+			want: `Created a parking lot with 6 slots on 2 floors
+Allocated slot number: 1-1 (ticket T000001)
+Allocated slot number: 1-2 (ticket T000002)
+Slot number 1-1 is free
+Unknown allocation strategy exit
+Added exit B next to slot number 2-3
+Allocation strategy is exit B
+Allocated slot number: 2-3 (ticket T000003)
+Allocation strategy is fill_by_floor
+Allocated slot number: 1-1 (ticket T000004)
+Allocation strategy is spread
+Allocated slot number: 1-3 (ticket T000005)
+Allocation strategy exit needs an exit added after creating the parking lot
+Unknown allocation strategy farthest
+`,
+		},
+		{name: "Entrances",
+			input: `create_parking_lot 6
+add_entrance B 6
+add_exit B 1
+set_distance B 3 0
+park KA-01-HH-1234 White gate=B
+park KA-01-HH-9999 White gate=B
+park KA-01-BB-0001 Black motorcycle gate=C
+park KA-01-HH-7777 Red
+set_distance C 1 0`,
+			want: `Created a parking lot with 6 slots
+Added entrance B next to slot number 6
+Gate B already exists
+Distance from B to slot number 3 is 0
+Allocated slot number: 3 (ticket T000001)
+Allocated slot number: 6 (ticket T000002)
+Unknown entrance C
+Allocated slot number: 1 (ticket T000003)
+Unknown gate C
+`,
+		},
+		{name: "Reservations",
+			input: `create_parking_lot 3
+time 2019-01-01T08:00
+reserve KA-01-HH-1234 until 2019-01-01T10:00
+reserve KA-01-HH-9999 3 until 2019-01-01T09:00
+reserve KA-01-HH-7777 2 until 2019-01-01T07:00
+park KA-01-BB-0001 Black
+park KA-01-HH-7777 Red
+park KA-01-HH-9999 White
+leave 3
+close_slot 1
+time 2019-01-01T10:00
+park KA-01-HH-7777 Red
+reserve KA-01-HH-2701 until 2019-01-01T11:00
+cancel_reservation KA-01-HH-2701
+cancel_reservation KA-01-HH-2701`,
+			want: `Created a parking lot with 3 slots
+Time is 2019-01-01T08:00
+Reserved slot number 1 for KA-01-HH-1234 until 2019-01-01T10:00
+Reserved slot number 3 for KA-01-HH-9999 until 2019-01-01T09:00
+Reservation must end in the future
+Allocated slot number: 2 (ticket T000001)
+Sorry, parking lot is full
+Allocated slot number: 3 (ticket T000002)
+Slot number 3 is free
+Slot is reserved
+Time is 2019-01-01T10:00
+Allocated slot number: 1 (ticket T000003)
+Reserved slot number 3 for KA-01-HH-2701 until 2019-01-01T11:00
+Slot number 3 is free
+Car KA-01-HH-2701 has no reservation
+`,
+		},
+		{name: "Waiting list",
+			input: `create_parking_lot 1
+set_waiting_list priority
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White
+park KA-01-BB-0001 Black priority=2
+park KA-01-HH-7777 Red priority=1
+park KA-01-HH-7777 Red
+waiting_list
+cancel_waiting KA-01-HH-9999
+leave 1
+waiting_list
+set_waiting_list off
+cancel_waiting KA-01-HH-7777
+set_waiting_list off
+park KA-01-HH-2701 Blue`,
+			want: `Created a parking lot with 1 slots
+Waiting list is priority
+Allocated slot number: 1 (ticket T000001)
+Car KA-01-HH-9999 is number 1 on the waiting list
+Car KA-01-BB-0001 is number 1 on the waiting list
+Car KA-01-HH-7777 is number 2 on the waiting list
+Car KA-01-HH-7777 is already waiting
+No.    Registration No    Colour    Priority
+1      KA-01-BB-0001      Black     2
+2      KA-01-HH-7777      Red       1
+3      KA-01-HH-9999      White     0
+Car KA-01-HH-9999 left the waiting list
+Slot number 1 is free
+Allocated slot number: 1 to KA-01-BB-0001 from the waiting list (ticket T000002)
+No.    Registration No    Colour    Priority
+1      KA-01-HH-7777      Red       1
+Waiting list is not empty
+Car KA-01-HH-7777 left the waiting list
+Waiting list is off
+Sorry, parking lot is full
+`,
+		},
+		{name: "Slot attributes",
+			input: `create_parking_lot 5
+set_slot_attribute accessible 1
+set_slot_attribute ev,vip 2 3
+clear_slot_attribute vip 3 9
+set_attribute_rule vip never
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White require=accessible
+park KA-01-BB-0001 Black prefer=ev
+park KA-01-HH-7777 Red
+park KA-01-HH-2701 Blue require=family
+park KA-01-HH-2701 Blue require=ev,vip
+status`,
+			want: `Created a parking lot with 5 slots
+Slot number 1 has attributes accessible
+Slot number 2 has attributes ev,vip
+Slot number 3 has attributes ev,vip
+Slot number 3 has attributes ev
+Invalid slot number
+Slots with attributes vip are given to other cars never
+Allocated slot number: 4 (ticket T000001)
+Allocated slot number: 1 (ticket T000002)
+Allocated slot number: 3 (ticket T000003)
+Allocated slot number: 5 (ticket T000004)
+Sorry, parking lot is full for class car with family
+Allocated slot number: 2 (ticket T000005)
+Slot No.    Registration No    Colour    Duration    Attributes
+1           KA-01-HH-9999      White     0h00m       accessible
+2           KA-01-HH-2701      Blue      0h00m       ev,vip
+3           KA-01-BB-0001      Black     0h00m       ev
+4           KA-01-HH-1234      White     0h00m       -
+5           KA-01-HH-7777      Red       0h00m       -
+`,
+		},
+		{name: "EV charging",
+			input: `create_parking_lot 3
+time 2019-01-01T08:00
+set_charger 2 7.4 30
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White require=ev
+start_charge 1
+start_charge 2
+time 2019-01-01T09:30
+stop_charge 2
+stop_charge 2
+start_charge 2
+time 2019-01-01T10:00
+charger_report
+leave 2`,
+			want: `Created a parking lot with 3 slots
+Time is 2019-01-01T08:00
+Slot number 2 has a 7.4 kW charger at 0.30 per kWh
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Slot has no charger
+Charging KA-01-HH-9999 at slot number 2
+Time is 2019-01-01T09:30
+Charged 11.10 kWh in 1h30m at slot number 2
+Amount due: 3.33
+Car is not charging
+Charging KA-01-HH-9999 at slot number 2
+Time is 2019-01-01T10:00
+Slot No.    Power     Sessions    Energy       Charging    Utilisation
+2           7.4 kW    1           11.10 kWh    2h00m       100%
+Slot number 2 is free
+Charged 14.80 kWh in 2h00m, amount due: 4.44
+`,
+		},
+		{name: "Move and swap cars",
+			input: `create_parking_lot 2x2
+park KA-01-HH-1234 White
+park KA-01-HH-9999 Red
+move 1-1 2-2
+move 1-2 2-2
+swap 1-2 2-2
+leave_ticket T000001
+slot_number_for_registration_number KA-01-HH-9999
+slot_numbers_for_cars_with_colour White
+park KA-01-BB-0001 Black`,
+			want: `Created a parking lot with 4 slots on 2 floors
+Allocated slot number: 1-1 (ticket T000001)
+Allocated slot number: 1-2 (ticket T000002)
+Moved KA-01-HH-1234 from slot number 1-1 to slot number 2-2
+Slot is occupied
+Swapped slot numbers 1-2 and 2-2
+Slot number 1-2 is free
+2-2
+Not found
+Allocated slot number: 1-1 (ticket T000003)
+`,
+		},
+		{name: "Compact carpark with van slots",
+			input: `create_parking_lot 5
+set_slot_class van 1 4
+park KA-01-HH-0001 White
+park KA-01-HH-0002 White
+park KA-01-HH-0003 White
+park KA-01-HH-0004 White van
+park KA-01-HH-0005 White
+leave 1
+leave 2
+compact apply`,
+			want: `Created a parking lot with 5 slots
+Slot number 1 is a van slot
+Slot number 4 is a van slot
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Allocated slot number: 3 (ticket T000003)
+Allocated slot number: 4 (ticket T000004)
+Allocated slot number: 5 (ticket T000005)
+Slot number 1 is free
+Slot number 2 is free
+Moved KA-01-HH-0005 from slot number 5 to slot number 2
+Moved KA-01-HH-0004 from slot number 4 to slot number 1
+`,
+		},
+		{name: "Compact carpark",
+			input: `compact
+create_parking_lot 5
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White
+park KA-01-BB-0001 Black
+park KA-01-HH-7777 Red
+leave 1
+leave 3
+compact
+compact apply
+compact
+park KA-01-HH-2701 Blue`,
+			want: `Carpark not initialized
+Created a parking lot with 5 slots
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Allocated slot number: 3 (ticket T000003)
+Allocated slot number: 4 (ticket T000004)
+Slot number 1 is free
+Slot number 3 is free
+Move KA-01-HH-7777 from slot number 4 to slot number 1
+Moved KA-01-HH-7777 from slot number 4 to slot number 1
+Parking lot is compact
+Allocated slot number: 3 (ticket T000005)
+`,
+		},
+		{name: "History",
+			input: `time 2026-10-18T08:00
+create_parking_lot 4
+park KA-01-HH-1234 White
+time 2026-10-18T09:00
+park KA-01-HH-9999 White
+time 2026-10-18T10:30
+leave 1
+move 2 3
+time 2026-10-18T12:00
+park KA-01-HH-1234 White
+status_at 2026-10-18T09:30
+status_at 2026-10-18T11:00
+status_at 2026-10-18T13:00
+history_for_registration_number KA-01-HH-1234
+history_for_registration_number KA-01-HH-9999
+history_for_registration_number KA-01-HH-7777`,
+			want: `Time is 2026-10-18T08:00
+Created a parking lot with 4 slots
+Allocated slot number: 1 (ticket T000001)
+Time is 2026-10-18T09:00
+Allocated slot number: 2 (ticket T000002)
+Time is 2026-10-18T10:30
+Slot number 1 is free
+Moved KA-01-HH-9999 from slot number 2 to slot number 3
+Time is 2026-10-18T12:00
+Allocated slot number: 1 (ticket T000003)
+Slot No.    Registration No    Colour    Duration
+1           KA-01-HH-1234      White     1h30m
+2           KA-01-HH-9999      White     0h30m
+Slot No.    Registration No    Colour    Duration
+3           KA-01-HH-9999      White     2h00m
+Time is in the future
+Ticket     Slot No.    Entry               Exit                Duration
+T000001    1           2026-10-18T08:00    2026-10-18T10:30    2h30m
+T000003    1           2026-10-18T12:00    -                   0h00m
+Ticket     Slot No.    Entry               Exit    Duration
+T000002    2, 3        2026-10-18T09:00    -       3h00m
+Not found
+`,
+		},
+		{name: "Undo after compacting",
+			input: `create_parking_lot 5
+park KA-01-HH-0001 White
+park KA-01-HH-0002 White
+park KA-01-HH-0003 White
+leave 3
+compact apply
+undo
+park KA-01-HH-0004 White
+status`,
+			want: `Created a parking lot with 5 slots
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Allocated slot number: 3 (ticket T000003)
+Slot number 3 is free
+Parking lot is compact
+Nothing to undo
+Allocated slot number: 3 (ticket T000004)
+Slot No.    Registration No    Colour    Duration
+1           KA-01-HH-0001      White     0h00m
+2           KA-01-HH-0002      White     0h00m
+3           KA-01-HH-0004      White     0h00m
+`,
+		},
+		{name: "Undo and redo",
+			input: `undo
+create_parking_lot 4
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White
+park KA-01-BB-0001 Black
+leave 1
+status
+undo
+undo
+status
+redo
+redo
+redo
+park KA-01-HH-7777 Red
+swap 1 2
+undo
+close_slot 4
+undo`,
+			want: `Nothing to undo
+Created a parking lot with 4 slots
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Allocated slot number: 3 (ticket T000003)
+Slot number 1 is free
+Slot No.    Registration No    Colour    Duration
+2           KA-01-HH-9999      White     0h00m
+3           KA-01-BB-0001      Black     0h00m
+Undid leave 1
+Undid park KA-01-BB-0001 Black
+Slot No.    Registration No    Colour    Duration
+1           KA-01-HH-1234      White     0h00m
+2           KA-01-HH-9999      White     0h00m
+Redid park KA-01-BB-0001 Black
+Redid leave 1
+Nothing to redo
+Allocated slot number: 1 (ticket T000004)
+Swapped slot numbers 1 and 2
+Undid swap 1 2
+Slot number 4 is closed
+Nothing to undo
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotBuf bytes.Buffer
+			outStream = &gotBuf
+			operateCarpark(&Carpark{}, bufio.NewScanner(strings.NewReader(tt.input)))
+			if got := gotBuf.String(); got != tt.want {
+				t.Errorf("operateCarpark() = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_main_state(t *testing.T) {
+	//Save old settings before rewriting settings
+	oldArgs := os.Args
+	oldOutStream := outStream
+	defer func() {
+		os.Args = oldArgs
+		outStream = oldOutStream
+	}()
+
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "state.json")
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "First run",
+			input: `create_parking_lot 3
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White
+leave 1`,
+			want: `Created a parking lot with 3 slots
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Slot number 1 is free
+`,
+		},
+		{name: "Restart",
+			input: `park KA-01-BB-0001 Black
+slot_number_for_registration_number KA-01-HH-9999`,
+			want: `Allocated slot number: 1 (ticket T000003)
+2
+`,
+		},
+		{name: "Save and load",
+			input: fmt.Sprintf(`save %[1]v
+park KA-01-HH-7777 Red
+load %[1]v
+park KA-01-HH-2701 Blue`, filepath.Join(dir, "saved.json")),
+			want: fmt.Sprintf(`Saved parking lot to %[1]v
+Allocated slot number: 3 (ticket T000004)
+Loaded parking lot from %[1]v
+Allocated slot number: 3 (ticket T000004)
+`, filepath.Join(dir, "saved.json")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputFile := filepath.Join(dir, "input.txt")
+			if err := os.WriteFile(inputFile, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var gotBuf bytes.Buffer
+			outStream = &gotBuf
+			os.Args = []string{"cmd", "-state", stateFile, inputFile}
+			main()
+			if got := gotBuf.String(); got != tt.want {
+				t.Errorf("main() = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_main_journal(t *testing.T) {
+	//Save old settings before rewriting settings
+	oldArgs := os.Args
+	oldOutStream := outStream
+	defer func() {
+		os.Args = oldArgs
+		outStream = oldOutStream
+	}()
+
+	dir := t.TempDir()
+	journalFile := filepath.Join(dir, "carpark.journal")
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "First run",
+			input: `create_parking_lot 3
+park KA-01-HH-1234 White
+park KA-01-HH-9999 White
+leave 1`,
+			want: `Created a parking lot with 3 slots
+Allocated slot number: 1 (ticket T000001)
+Allocated slot number: 2 (ticket T000002)
+Slot number 1 is free
+`,
+		},
+		{name: "Restart without saving the state",
+			input: `park KA-01-BB-0001 Black
+slot_number_for_registration_number KA-01-HH-9999
+load state.json`,
+			want: `Allocated slot number: 1 (ticket T000003)
+2
+Cannot load a parking lot while journaling
+`,
+		},
+		{name: "Change the slots",
+			input: `expand_parking_lot 1
+park KA-01-HH-7777 Red
+park KA-01-HH-2701 Blue
+leave 1
+compact apply
+leave 1`,
+			want: `Expanded parking lot to 4 slots
+Allocated slot number: 3 (ticket T000004)
+Allocated slot number: 4 (ticket T000005)
+Slot number 1 is free
+Moved KA-01-HH-2701 from slot number 4 to slot number 1
+Slot number 1 is free
+`,
+		},
+		{name: "Restart after changing the slots",
+			input: `park KA-01-HH-3141 Black
+status`,
+			want: `Allocated slot number: 1 (ticket T000006)
+Slot No.    Registration No    Colour    Duration
+1           KA-01-HH-3141      Black     0h00m
+2           KA-01-HH-9999      White     0h00m
+3           KA-01-HH-7777      Red       0h00m
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputFile := filepath.Join(dir, "input.txt")
+			if err := os.WriteFile(inputFile, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var gotBuf bytes.Buffer
+			outStream = &gotBuf
+			os.Args = []string{"cmd", "-journal", journalFile, inputFile}
+			main()
+			if got := gotBuf.String(); got != tt.want {
+				t.Errorf("main() = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseFloors(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    string
+		want    []int
+		wantErr bool
+	}{
+		{name: "Slots", size: "6", want: []int{6}, wantErr: false},
+		{name: "Floors of equal size", size: "3x2", want: []int{2, 2, 2}, wantErr: false},
+		{name: "Slots per floor", size: "4,2", want: []int{4, 2}, wantErr: false},
+		{name: "Too many floors", size: "2000000000x1", want: nil, wantErr: true},
+		{name: "No floors", size: "0x4", want: nil, wantErr: true},
+		{name: "Not a number", size: "ax4", want: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFloors(tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseFloors() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFloors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
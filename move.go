@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+)
+
+//Move the car parked in a slot to an empty slot, keeping its ticket and timestamps
+func (carpark *Carpark) moveCar(from int, to int) (*Car, error) {
+	if err := carpark.initStatus(); err != nil {
+		return nil, err
+	}
+	carpark.expireReservations()
+	car, err := carpark.movableCar(from)
+	if err != nil {
+		return nil, err
+	}
+	if err := carpark.checkEmptySlot(to); err != nil {
+		return nil, err
+	}
+	if !car.class.fits(carpark.slotClass[to]) {
+		return nil, errors.New("Car does not fit the slot")
+	}
+	before := carpark.frontier()
+	carpark.claimSlot(to)
+	delete(carpark.Map, from)
+	carpark.unindexColour(car)
+	carpark.relocate(car, to)
+	carpark.indexColour(car)
+	//Add the vacated slot to the heap of its slot class
+	carpark.emptySlot[carpark.slotClass[from]].Push(from)
+	carpark.track(&relocation{car: car, from: from, to: to, frontier: carpark.passedSince(before, to), time: carpark.now()})
+	return car, carpark.record(journalRecord{Op: "move", Time: carpark.now(), Slot: from, To: to})
+}
+
+//Swap the cars parked in two slots
+func (carpark *Carpark) swapCars(a int, b int) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	if a == b {
+		return errors.New("Cannot swap a slot with itself")
+	}
+	carA, err := carpark.movableCar(a)
+	if err != nil {
+		return err
+	}
+	carB, err := carpark.movableCar(b)
+	if err != nil {
+		return err
+	}
+	if !carA.class.fits(carpark.slotClass[b]) || !carB.class.fits(carpark.slotClass[a]) {
+		return errors.New("Car does not fit the slot")
+	}
+	//Unindex both cars before either is moved, as they may have the same colour
+	carpark.unindexColour(carA)
+	carpark.unindexColour(carB)
+	carpark.relocate(carA, b)
+	carpark.relocate(carB, a)
+	carpark.indexColour(carA)
+	carpark.indexColour(carB)
+	carpark.track(&exchange{a: a, b: b, time: carpark.now()})
+	return carpark.record(journalRecord{Op: "swap", Time: carpark.now(), Slot: a, To: b})
+}
+
+//Retrieve the car parked in a slot, which must not be charging to be moved
+func (carpark *Carpark) movableCar(slotNo int) (*Car, error) {
+	car, ok := carpark.Map[slotNo]
+	if !ok {
+		return nil, errors.New("Car non-existent in carpark")
+	}
+	if !car.chargeStart.IsZero() {
+		return nil, errors.New("Car is charging")
+	}
+	return car, nil
+}
+
+//Put a car into a slot, updating the indexes of parked cars by ticket and registration number, and the event log
+func (carpark *Carpark) relocate(car *Car, slotNo int) {
+	carpark.place(car, slotNo)
+	carpark.slotUses[slotNo]++
+	carpark.logEvent(moveEvent, car, carpark.now())
+}
+
+//Put a car into a slot, updating the indexes of parked cars by ticket and registration number
+func (carpark *Carpark) place(car *Car, slotNo int) {
+	car.slot = slotNo
+	carpark.Map[slotNo] = car
+	carpark.tickets[car.ticket] = slotNo
+	carpark.regs[normalizeRegistration(car.registration)] = slotNo
+}
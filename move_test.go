@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCarpark_moveCar(t *testing.T) {
+	now := values().now
+	type args struct {
+		from int
+		to   int
+	}
+	tests := []struct {
+		name        string
+		carpark     *Carpark
+		args        args
+		wantErr     bool
+		wantCarpark *Carpark
+	}{
+		{name: "Carpark not initialized",
+			carpark:     &Carpark{},
+			args:        args{from: 1, to: 2},
+			wantErr:     true,
+			wantCarpark: &Carpark{},
+		},
+		{name: "Move car beyond highestSlot",
+			carpark:     indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+			args:        args{from: 1, to: 4},
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: map[int]*Car{2: values().car2, 4: {slot: 4, registration: "KA-01-HH-1234", colour: "White", entry: now, ticket: "T000001"}}, emptySlot: emptySlots(1, 3), highestSlot: 4, maxSlot: 10}),
+		},
+		{name: "Move car into previously occupied slot",
+			carpark:     indexed(&Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10}),
+			args:        args{from: 2, to: 1},
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: map[int]*Car{1: {slot: 1, registration: "KA-01-HH-7777", colour: "Red", entry: now, ticket: "T000002"}}, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10}),
+		},
+		{name: "Move car into occupied slot",
+			carpark:     indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+			args:        args{from: 1, to: 2},
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+		},
+		{name: "Move car into closed slot",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, closed: map[int]bool{3: true}}),
+			args:        args{from: 1, to: 3},
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, closed: map[int]bool{3: true}}),
+		},
+		{name: "Move car into slot it does not fit",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, slotClass: map[int]VehicleClass{3: motorcycleClass}}),
+			args:        args{from: 1, to: 3},
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10, slotClass: map[int]VehicleClass{3: motorcycleClass}}),
+		},
+		{name: "Move car from empty slot",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+			args:        args{from: 2, to: 3},
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+		},
+		{name: "Move car which is charging",
+			carpark:     indexed(&Carpark{Map: map[int]*Car{1: {slot: 1, chargeStart: now}}, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+			args:        args{from: 1, to: 2},
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: map[int]*Car{1: {slot: 1, chargeStart: now}}, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.carpark.slotUses = map[int]int{}
+			if _, err := tt.carpark.moveCar(tt.args.from, tt.args.to); (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.moveCar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			compareCarpark(t, tt.carpark, tt.wantCarpark)
+		})
+	}
+}
+
+func TestCarpark_swapCars(t *testing.T) {
+	now := values().now
+	white := func(slotNo int, registration string, ticket string) *Car {
+		return &Car{slot: slotNo, registration: registration, colour: "White", entry: now, ticket: ticket}
+	}
+	tests := []struct {
+		name        string
+		carpark     *Carpark
+		a           int
+		b           int
+		wantErr     bool
+		wantCarpark *Carpark
+	}{
+		{name: "Swap cars",
+			carpark:     indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+			a:           1,
+			b:           2,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: map[int]*Car{1: {slot: 1, registration: "KA-01-HH-7777", colour: "Red", entry: now, ticket: "T000002"}, 2: {slot: 2, registration: "KA-01-HH-1234", colour: "White", entry: now, ticket: "T000001"}}, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+		},
+		{name: "Swap cars of the same colour",
+			carpark:     indexed(&Carpark{Map: map[int]*Car{1: white(1, "KA-01-HH-1234", "T000001"), 3: white(3, "KA-01-HH-9999", "T000002")}, emptySlot: values().emptySlot2, highestSlot: 3, maxSlot: 10}),
+			a:           3,
+			b:           1,
+			wantErr:     false,
+			wantCarpark: indexed(&Carpark{Map: map[int]*Car{1: white(1, "KA-01-HH-9999", "T000002"), 3: white(3, "KA-01-HH-1234", "T000001")}, emptySlot: values().emptySlot2, highestSlot: 3, maxSlot: 10}),
+		},
+		{name: "Swap with an empty slot",
+			carpark:     indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10}),
+			a:           1,
+			b:           2,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot2, highestSlot: 2, maxSlot: 10}),
+		},
+		{name: "Swap a slot with itself",
+			carpark:     indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+			a:           1,
+			b:           1,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+		},
+		{name: "Swap a van into a car slot",
+			carpark:     indexed(&Carpark{Map: map[int]*Car{1: values().car1, 2: {slot: 2, class: vanClass}}, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, slotClass: map[int]VehicleClass{2: vanClass}}),
+			a:           1,
+			b:           2,
+			wantErr:     true,
+			wantCarpark: indexed(&Carpark{Map: map[int]*Car{1: values().car1, 2: {slot: 2, class: vanClass}}, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, slotClass: map[int]VehicleClass{2: vanClass}}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.carpark.slotUses = map[int]int{}
+			if err := tt.carpark.swapCars(tt.a, tt.b); (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.swapCars() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			compareCarpark(t, tt.carpark, tt.wantCarpark)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+//defaultColourSynonyms maps alternative colour names to the colour they are a synonym of
+var defaultColourSynonyms = map[string]string{
+	"gray":   "grey",
+	"silver": "grey",
+}
+
+//normalizeRegistration converts a registration number into a canonical form, which ignores case and separators
+func normalizeRegistration(registration string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '.' || r == '_' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(registration))
+}
+
+//Convert a colour into a canonical form, which ignores case and resolves colour synonyms
+func (carpark *Carpark) normalizeColour(colour string) string {
+	colour = strings.ToLower(colour)
+	if synonym, ok := carpark.synonyms[colour]; ok {
+		return synonym
+	}
+	return colour
+}
+
+//Treat a colour as a synonym of another colour in all colour queries
+func (carpark *Carpark) addColourSynonym(colour string, canonical string) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	colour = strings.ToLower(colour)
+	canonical = carpark.normalizeColour(canonical)
+	if colour == canonical {
+		return errors.New("Colour cannot be a synonym of itself")
+	}
+	for synonym, target := range carpark.synonyms {
+		if target == colour {
+			carpark.synonyms[synonym] = canonical
+		}
+	}
+	carpark.synonyms[colour] = canonical
+	//Rebuild the index of car colours with the new synonym
+	carpark.colours = make(map[string]*slotSet)
+	for _, car := range carpark.Map {
+		carpark.indexColour(car)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_normalizeRegistration(t *testing.T) {
+	tests := []struct {
+		name         string
+		registration string
+		want         string
+	}{
+		{name: "Dashes", registration: "KA-01-HH-1234", want: "KA01HH1234"},
+		{name: "Spaces and lower case", registration: "ka 01 hh 1234", want: "KA01HH1234"},
+		{name: "No separators", registration: "KA01HH1234", want: "KA01HH1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeRegistration(tt.registration); got != tt.want {
+				t.Errorf("normalizeRegistration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCarpark_addColourSynonym(t *testing.T) {
+	type args struct {
+		colour    string
+		canonical string
+	}
+	tests := []struct {
+		name         string
+		carpark      *Carpark
+		args         args
+		wantErr      bool
+		wantSynonyms map[string]string
+		wantColours  map[string]*slotSet
+	}{
+		{name: "Carpark not initialized",
+			carpark: &Carpark{},
+			args:    args{colour: "Crimson", canonical: "Red"},
+			wantErr: true,
+		},
+		{name: "New synonym of a parked colour",
+			carpark:      indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, synonyms: map[string]string{}}),
+			args:         args{colour: "Red", canonical: "Crimson"},
+			wantErr:      false,
+			wantSynonyms: map[string]string{"red": "crimson"},
+			wantColours:  map[string]*slotSet{"white": {1}, "crimson": {2}},
+		},
+		{name: "Synonym of a synonym",
+			carpark:      indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, synonyms: map[string]string{"scarlet": "red"}}),
+			args:         args{colour: "Crimson", canonical: "Scarlet"},
+			wantErr:      false,
+			wantSynonyms: map[string]string{"scarlet": "red", "crimson": "red"},
+			wantColours:  map[string]*slotSet{"white": {1}, "red": {2}},
+		},
+		{name: "Synonym of itself",
+			carpark:      indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10, synonyms: map[string]string{"gray": "grey"}}),
+			args:         args{colour: "Grey", canonical: "Gray"},
+			wantErr:      true,
+			wantSynonyms: map[string]string{"gray": "grey"},
+			wantColours:  map[string]*slotSet{"white": {1}, "red": {2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.carpark.addColourSynonym(tt.args.colour, tt.args.canonical)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.addColourSynonym() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(tt.carpark.synonyms, tt.wantSynonyms) {
+				t.Errorf("Carpark.addColourSynonym() synonyms = %v, want %v", tt.carpark.synonyms, tt.wantSynonyms)
+			}
+			if !reflect.DeepEqual(tt.carpark.colours, tt.wantColours) {
+				t.Errorf("Carpark.addColourSynonym() colours = %v, want %v", tt.carpark.colours, tt.wantColours)
+			}
+		})
+	}
+}
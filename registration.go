@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//RegistrationValidator verifies the format of registration numbers
+type RegistrationValidator interface {
+	Validate(registration string) error
+}
+
+//registrationFormats holds the patterns of the built-in registration number formats of each region, which ignore case
+var registrationFormats = map[string]string{
+	"india": `(?i)^[A-Z]{2}-[0-9]{1,2}-[A-Z]{1,3}-[0-9]{1,4}$`, //e.g. KA-01-HH-1234
+	"uk":    `(?i)^[A-Z]{2}[0-9]{2}-?[A-Z]{3}$`,                //e.g. AB12-CDE
+	"eu":    `(?i)^[A-Z]{1,3}-[A-Z0-9]{1,4}(-[A-Z0-9]{1,4})?$`, //e.g. B-MW-1234 or AB-123-CD
+	"any":   `(?i)^[A-Z0-9]+([- ][A-Z0-9]+)*$`,                 //Letters and digits, optionally separated
+}
+
+//regexValidator accepts registration numbers which match a regular expression
+type regexValidator struct {
+	format  string         //Name of the registration number format
+	pattern *regexp.Regexp //Pattern of valid registration numbers
+}
+
+//Validate verifies that a registration number matches the pattern of the validator
+func (validator *regexValidator) Validate(registration string) error {
+	if !validator.pattern.MatchString(strings.TrimSpace(registration)) {
+		return fmt.Errorf("Invalid %v registration number %v", validator.format, registration)
+	}
+	return nil
+}
+
+//newRegistrationValidator creates a validator of a built-in registration number format, or of a
+//custom regular expression when the format is "regex"
+func newRegistrationValidator(format string, pattern string) (RegistrationValidator, error) {
+	if format != "regex" {
+		builtIn, ok := registrationFormats[format]
+		if !ok || pattern != "" {
+			return nil, fmt.Errorf("Unknown registration number format %v", format)
+		}
+		pattern = builtIn
+	}
+	if pattern == "" {
+		return nil, errors.New("Missing registration number pattern")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid registration number pattern: %v", err)
+	}
+	return &regexValidator{format: format, pattern: re}, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import "testing"
+
+func Test_newRegistrationValidator(t *testing.T) {
+	type args struct {
+		format  string
+		pattern string
+	}
+	tests := []struct {
+		name         string
+		args         args
+		registration string
+		wantErr      bool
+		wantInvalid  bool
+	}{
+		{name: "Indian registration number",
+			args:         args{format: "india"},
+			registration: "KA-01-HH-1234",
+		},
+		{name: "Lower case Indian registration number",
+			args:         args{format: "india"},
+			registration: "ka-01-p-333",
+		},
+		{name: "Truncated Indian registration number",
+			args:         args{format: "india"},
+			registration: "MH-04-AY-",
+			wantInvalid:  true,
+		},
+		{name: "UK registration number",
+			args:         args{format: "uk"},
+			registration: "AB12CDE",
+		},
+		{name: "Indian registration number in UK format",
+			args:         args{format: "uk"},
+			registration: "KA-01-HH-1234",
+			wantInvalid:  true,
+		},
+		{name: "EU registration number",
+			args:         args{format: "eu"},
+			registration: "B-MW-1234",
+		},
+		{name: "Custom pattern",
+			args:         args{format: "regex", pattern: "^[A-Z]{3}[0-9]{3}$"},
+			registration: "ABC123",
+		},
+		{name: "Registration number outside custom pattern",
+			args:         args{format: "regex", pattern: "^[A-Z]{3}[0-9]{3}$"},
+			registration: "AB1234",
+			wantInvalid:  true,
+		},
+		{name: "Lower case custom pattern",
+			args:         args{format: "regex", pattern: "^[a-z]{3}[0-9]{3}$"},
+			registration: "abc123",
+		},
+		{name: "Upper case registration number outside lower case custom pattern",
+			args:         args{format: "regex", pattern: "^[a-z]{3}[0-9]{3}$"},
+			registration: "ABC123",
+			wantInvalid:  true,
+		},
+		{name: "Case insensitive custom pattern",
+			args:         args{format: "regex", pattern: "(?i)^[A-Z]{3}[0-9]{3}$"},
+			registration: "abc123",
+		},
+		{name: "Empty custom pattern",
+			args:    args{format: "regex"},
+			wantErr: true,
+		},
+		{name: "Malformed custom pattern",
+			args:    args{format: "regex", pattern: "[A-Z"},
+			wantErr: true,
+		},
+		{name: "Unknown format",
+			args:    args{format: "mars"},
+			wantErr: true,
+		},
+		{name: "Pattern given to built-in format",
+			args:    args{format: "india", pattern: "^[A-Z]+$"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := newRegistrationValidator(tt.args.format, tt.args.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newRegistrationValidator() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if err := validator.Validate(tt.registration); (err != nil) != tt.wantInvalid {
+				t.Errorf("RegistrationValidator.Validate() error = %v, wantInvalid %v", err, tt.wantInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"minheap"
+	"time"
+)
+
+//reservation holds an empty slot for a car until it arrives, or until the reservation expires
+type reservation struct {
+	registration string    //Registration number of the car the slot is held for
+	slot         int       //Slot number which is held
+	until        time.Time //Time at which the reservation expires
+}
+
+//newReservationHeap returns an empty heap of reservations in order of expiry
+func newReservationHeap() *minheap.Heap[*reservation] {
+	return minheap.New(func(a, b *reservation) bool {
+		if !a.until.Equal(b.until) {
+			return a.until.Before(b.until)
+		}
+		return a.slot < b.slot
+	})
+}
+
+//Reserve a slot for a car until the given time, choosing the slot when slotNo is 0
+func (carpark *Carpark) reserve(registration string, slotNo int, until time.Time) (int, error) {
+	if err := carpark.initStatus(); err != nil {
+		return 0, err
+	}
+	carpark.expireReservations()
+	if carpark.validator != nil {
+		if err := carpark.validator.Validate(registration); err != nil {
+			return 0, err
+		}
+	}
+	reg := normalizeRegistration(registration)
+	if _, ok := carpark.regs[reg]; ok {
+		return 0, fmt.Errorf("Car %v is already parked", registration)
+	}
+	if _, ok := carpark.reservations[reg]; ok {
+		return 0, fmt.Errorf("Car %v already has a reservation", registration)
+	}
+	if !until.After(carpark.now()) {
+		return 0, errors.New("Reservation must end in the future")
+	}
+	if slotNo == 0 {
+		//Choose a slot as if the car was parked now
+		if len(carpark.Map)+len(carpark.closed)+len(carpark.reserved) == carpark.maxSlot {
+			return 0, errFull
+		}
+		var ok bool
+		slotNo, ok = carpark.allocate(&Car{registration: registration})
+		if !ok {
+			return 0, fmt.Errorf("%w for class %v", errFull, carClass)
+		}
+	} else {
+		if err := carpark.checkEmptySlot(slotNo); err != nil {
+			return 0, err
+		}
+		carpark.claimSlot(slotNo)
+	}
+	carpark.addReservation(&reservation{registration: registration, slot: slotNo, until: until})
+	return slotNo, carpark.record(journalRecord{Op: "reserve", Time: carpark.now(), Slot: slotNo, Registration: registration, Until: &until})
+}
+
+//Hold the slot of a reservation, which must already be taken out of the heaps of empty slots
+func (carpark *Carpark) addReservation(booking *reservation) {
+	carpark.reservations[normalizeRegistration(booking.registration)] = booking
+	carpark.reserved[booking.slot] = booking
+	carpark.expiries.Push(booking)
+}
+
+//Take the slot reserved for a car, which is 0 when the car has no reservation
+func (carpark *Carpark) reservedSlot(car *Car) (int, error) {
+	booking, ok := carpark.reservations[normalizeRegistration(car.registration)]
+	if !ok {
+		return 0, nil
+	}
+	if !car.class.fits(carpark.slotClass[booking.slot]) {
+		return 0, fmt.Errorf("Reserved slot does not fit a %v", car.class)
+	}
+	carpark.dropReservation(booking)
+	return booking.slot, nil
+}
+
+//Check that a slot is in service and neither occupied nor reserved
+func (carpark *Carpark) checkEmptySlot(slotNo int) error {
+	if slotNo < 1 || slotNo > carpark.maxSlot {
+		return errors.New("Invalid slot number")
+	}
+	if _, ok := carpark.Map[slotNo]; ok {
+		return errors.New("Slot is occupied")
+	}
+	if carpark.closed[slotNo] {
+		return errors.New("Slot is closed")
+	}
+	if _, ok := carpark.reserved[slotNo]; ok {
+		return errors.New("Slot is reserved")
+	}
+	return nil
+}
+
+//Cancel the reservation of a car, returning its slot to the empty slots
+func (carpark *Carpark) cancelReservation(registration string) (int, error) {
+	if err := carpark.initStatus(); err != nil {
+		return 0, err
+	}
+	carpark.expireReservations()
+	booking, ok := carpark.reservations[normalizeRegistration(registration)]
+	if !ok {
+		return 0, fmt.Errorf("Car %v has no reservation", registration)
+	}
+	carpark.dropReservation(booking)
+	carpark.emptySlot[carpark.slotClass[booking.slot]].Push(booking.slot)
+	return booking.slot, carpark.record(journalRecord{Op: "cancel_reservation", Time: carpark.now(), Registration: registration})
+}
+
+//Release the slots of reservations which have expired by the current time back to the empty slots
+func (carpark *Carpark) expireReservations() {
+	now := carpark.now()
+	for len(carpark.reservations) > 0 {
+		booking, _ := carpark.expiries.Peek()
+		if booking.until.After(now) {
+			return
+		}
+		carpark.dropReservation(booking)
+		carpark.emptySlot[carpark.slotClass[booking.slot]].Push(booking.slot)
+	}
+}
+
+//Remove a reservation from the carpark, leaving its slot out of the empty slots
+func (carpark *Carpark) dropReservation(booking *reservation) {
+	delete(carpark.reservations, normalizeRegistration(booking.registration))
+	delete(carpark.reserved, booking.slot)
+	carpark.expiries.Remove(booking)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+//reservations returns a carpark with the given reservations and their indexes
+func reservations(carpark *Carpark, bookings ...*reservation) *Carpark {
+	carpark.reservations = make(map[string]*reservation)
+	carpark.reserved = make(map[int]*reservation)
+	carpark.expiries = newReservationHeap()
+	for _, booking := range bookings {
+		carpark.reservations[normalizeRegistration(booking.registration)] = booking
+		carpark.reserved[booking.slot] = booking
+		carpark.expiries.Push(booking)
+	}
+	return carpark
+}
+
+//compareReservations compares the reservations of a carpark, and their indexes, with the wanted reservations
+func compareReservations(t *testing.T, carpark *Carpark, wantReserved map[int]*reservation) {
+	if !reflect.DeepEqual(carpark.reserved, wantReserved) ||
+		len(carpark.reservations) != len(wantReserved) ||
+		carpark.expiries.Len() != len(wantReserved) {
+		t.Errorf("reserved = %v, want %v", carpark.reserved, wantReserved)
+	}
+}
+
+func TestCarpark_reserve(t *testing.T) {
+	now := values().now
+	type args struct {
+		registration string
+		slotNo       int
+		until        time.Time
+	}
+	tests := []struct {
+		name         string
+		carpark      *Carpark
+		args         args
+		want         int
+		wantErr      bool
+		wantCarpark  *Carpark
+		wantReserved map[int]*reservation
+	}{
+		{name: "Carpark not initialized",
+			carpark:      reservations(&Carpark{}),
+			args:         args{registration: "KA-01-HH-2701", until: now.Add(time.Hour)},
+			want:         0,
+			wantErr:      true,
+			wantCarpark:  &Carpark{},
+			wantReserved: map[int]*reservation{},
+		},
+		{name: "Reserve the nearest slot",
+			carpark:      reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10})),
+			args:         args{registration: "KA-01-HH-2701", until: now.Add(time.Hour)},
+			want:         2,
+			wantErr:      false,
+			wantCarpark:  indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+			wantReserved: map[int]*reservation{2: {registration: "KA-01-HH-2701", slot: 2, until: now.Add(time.Hour)}},
+		},
+		{name: "Reserve a given slot",
+			carpark:      reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10})),
+			args:         args{registration: "KA-01-HH-2701", slotNo: 4, until: now.Add(time.Hour)},
+			want:         4,
+			wantErr:      false,
+			wantCarpark:  indexed(&Carpark{Map: values().map1, emptySlot: emptySlots(2, 3), highestSlot: 4, maxSlot: 10}),
+			wantReserved: map[int]*reservation{4: {registration: "KA-01-HH-2701", slot: 4, until: now.Add(time.Hour)}},
+		},
+		{name: "Reserve a previously occupied slot",
+			carpark:      reservations(indexed(&Carpark{Map: values().map2, emptySlot: values().emptySlot1, highestSlot: 2, maxSlot: 10})),
+			args:         args{registration: "KA-01-HH-2701", slotNo: 1, until: now.Add(time.Hour)},
+			want:         1,
+			wantErr:      false,
+			wantCarpark:  indexed(&Carpark{Map: values().map2, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+			wantReserved: map[int]*reservation{1: {registration: "KA-01-HH-2701", slot: 1, until: now.Add(time.Hour)}},
+		},
+		{name: "Reserve an occupied slot",
+			carpark:      reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10})),
+			args:         args{registration: "KA-01-HH-2701", slotNo: 1, until: now.Add(time.Hour)},
+			want:         0,
+			wantErr:      true,
+			wantCarpark:  indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+			wantReserved: map[int]*reservation{},
+		},
+		{name: "Reserve a slot which is already reserved",
+			carpark:      reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}), &reservation{registration: "KA-01-HH-7777", slot: 2, until: now.Add(time.Hour)}),
+			args:         args{registration: "KA-01-HH-2701", slotNo: 2, until: now.Add(time.Hour)},
+			want:         0,
+			wantErr:      true,
+			wantCarpark:  indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+			wantReserved: map[int]*reservation{2: {registration: "KA-01-HH-7777", slot: 2, until: now.Add(time.Hour)}},
+		},
+		{name: "Second reservation of a car",
+			carpark:      reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}), &reservation{registration: "KA-01-HH-2701", slot: 2, until: now.Add(time.Hour)}),
+			args:         args{registration: "KA-01-HH-2701", until: now.Add(time.Hour)},
+			want:         0,
+			wantErr:      true,
+			wantCarpark:  indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 10}),
+			wantReserved: map[int]*reservation{2: {registration: "KA-01-HH-2701", slot: 2, until: now.Add(time.Hour)}},
+		},
+		{name: "Reserve for a parked car",
+			carpark:      reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10})),
+			args:         args{registration: "KA-01-HH-1234", until: now.Add(time.Hour)},
+			want:         0,
+			wantErr:      true,
+			wantCarpark:  indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+			wantReserved: map[int]*reservation{},
+		},
+		{name: "Reservation ending in the past",
+			carpark:      reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10})),
+			args:         args{registration: "KA-01-HH-2701", until: now},
+			want:         0,
+			wantErr:      true,
+			wantCarpark:  indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+			wantReserved: map[int]*reservation{},
+		},
+		{name: "Reserve in a full carpark",
+			carpark:      reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 2}), &reservation{registration: "KA-01-HH-7777", slot: 2, until: now.Add(time.Hour)}),
+			args:         args{registration: "KA-01-HH-2701", until: now.Add(time.Hour)},
+			want:         0,
+			wantErr:      true,
+			wantCarpark:  indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 2}),
+			wantReserved: map[int]*reservation{2: {registration: "KA-01-HH-7777", slot: 2, until: now.Add(time.Hour)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.carpark.clock = &manualClock{now: now}
+			got, err := tt.carpark.reserve(tt.args.registration, tt.args.slotNo, tt.args.until)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Carpark.reserve() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Carpark.reserve() = %v, want %v", got, tt.want)
+			}
+			compareCarpark(t, tt.carpark, tt.wantCarpark)
+			compareReservations(t, tt.carpark, tt.wantReserved)
+		})
+	}
+}
+
+func TestCarpark_expireReservationsBeforeSlotChanges(t *testing.T) {
+	now := values().now
+	tests := []struct {
+		name   string
+		change func(carpark *Carpark) error
+	}{
+		{name: "Shrink",
+			change: func(carpark *Carpark) error { return carpark.shrink(3) },
+		},
+		{name: "Set slot class",
+			change: func(carpark *Carpark) error { return carpark.setSlotClass(2, vanClass) },
+		},
+		{name: "Close slot",
+			change: func(carpark *Carpark) error { return carpark.closeSlot(2) },
+		},
+		{name: "Move a car",
+			change: func(carpark *Carpark) error {
+				_, err := carpark.moveCar(1, 2)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carpark := reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 4, floors: []int{4}, slotClass: map[int]VehicleClass{}, closed: map[int]bool{}, slotUses: map[int]int{}}),
+				&reservation{registration: "KA-01-HH-7777", slot: 2, until: now.Add(-time.Hour)})
+			carpark.clock = &manualClock{now: now}
+			if err := tt.change(carpark); err != nil {
+				t.Errorf("change of an expired reserved slot error = %v, wantErr false", err)
+			}
+			compareReservations(t, carpark, map[int]*reservation{})
+		})
+	}
+}
+
+func TestCarpark_expireReservations(t *testing.T) {
+	now := values().now
+	tests := []struct {
+		name         string
+		carpark      *Carpark
+		wantCarpark  *Carpark
+		wantReserved map[int]*reservation
+	}{
+		{name: "No reservations",
+			carpark:      indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+			wantCarpark:  indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 10}),
+			wantReserved: nil,
+		},
+		{name: "Release expired reservations only",
+			carpark: reservations(indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 4, maxSlot: 10}),
+				&reservation{registration: "KA-01-HH-7777", slot: 2, until: now},
+				&reservation{registration: "KA-01-HH-2701", slot: 3, until: now.Add(time.Minute)},
+				&reservation{registration: "KA-01-HH-9999", slot: 4, until: now.Add(-time.Hour)}),
+			wantCarpark:  indexed(&Carpark{Map: values().map1, emptySlot: emptySlots(2, 4), highestSlot: 4, maxSlot: 10}),
+			wantReserved: map[int]*reservation{3: {registration: "KA-01-HH-2701", slot: 3, until: now.Add(time.Minute)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.carpark.clock = &manualClock{now: now}
+			tt.carpark.expireReservations()
+			compareCarpark(t, tt.carpark, tt.wantCarpark)
+			if !reflect.DeepEqual(tt.carpark.reserved, tt.wantReserved) {
+				t.Errorf("reserved = %v, want %v", tt.carpark.reserved, tt.wantReserved)
+			}
+		})
+	}
+}
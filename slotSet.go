@@ -0,0 +1,25 @@
+package main
+
+import "sort"
+
+//slotSet is a set of slot numbers kept in ascending order
+type slotSet []int
+
+//add inserts a slot number into the set
+func (set *slotSet) add(slotNo int) {
+	i := sort.SearchInts(*set, slotNo)
+	if i < len(*set) && (*set)[i] == slotNo {
+		return
+	}
+	*set = append(*set, 0)
+	copy((*set)[i+1:], (*set)[i:])
+	(*set)[i] = slotNo
+}
+
+//remove deletes a slot number from the set
+func (set *slotSet) remove(slotNo int) {
+	i := sort.SearchInts(*set, slotNo)
+	if i < len(*set) && (*set)[i] == slotNo {
+		*set = append((*set)[:i], (*set)[i+1:]...)
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//snapshotVersion is the version of the snapshot format written by save, which load refuses to read if different
+const snapshotVersion = 1
+
+//snapshot is the on-disk format of the full state of a carpark
+type snapshot struct {
+	Version      int                            `json:"version"`                       //Version of the snapshot format
+	Floors       []int                          `json:"floors"`                        //Number of slots on each floor
+	MaxSlot      int                            `json:"max_slot"`                      //Maximum number of slots available
+	HighestSlot  int                            `json:"highest_slot"`                  //Highest number of slots filled
+	EmptySlots   map[string][]int               `json:"empty_slots"`                   //Empty slots in the heap of each slot class, keyed by class name
+	Cars         []snapshotCar                  `json:"cars"`                          //Parked cars in slot order
+	SlotClasses  map[int]string                 `json:"slot_classes"`                  //Class name of each slot which is not a car slot
+	Closed       []int                          `json:"closed"`                        //Slots which are out of service
+	Clock        *time.Time                     `json:"clock,omitempty"`               //Time of the manual clock, or nil for the system clock
+	Tariff       *Tariff                        `json:"tariff,omitempty"`              //Parking charges, or nil when parking is free
+	Synonyms     map[string]string              `json:"synonyms"`                      //Colour which each alternative colour name is a synonym of
+	Registration *snapshotFormat                `json:"registration_format,omitempty"` //Format of registration numbers, or nil to accept any
+	Strategy     []string                       `json:"strategy,omitempty"`            //Name and arguments of the allocation strategy
+	RandomDraws  int64                          `json:"random_draws,omitempty"`        //Numbers drawn by the random strategy, which are drawn again on restore
+	Entrances    map[string]snapshotAccessPoint `json:"entrances"`                     //Named entrances
+	Exits        map[string]snapshotAccessPoint `json:"exits"`                         //Named exits
+	SlotUses     map[int]int                    `json:"slot_uses"`                     //Number of times each slot has been allocated
+	Reservations []snapshotReservation          `json:"reservations"`                  //Reservations in order of expiry
+	Attributes   map[int]string                 `json:"attributes"`                    //Attributes of each slot with attributes
+	Exclusive    string                         `json:"exclusive"`                     //Attributes whose slots are never given to cars not asking for them
+	Chargers     map[int]snapshotCharger        `json:"chargers"`                      //EV chargers installed at each slot
+	Waiting      *snapshotWaitingList           `json:"waiting_list,omitempty"`        //Cars waiting for a slot, or nil when cars are turned away
+	TicketNo     int                            `json:"ticket_no"`                     //Number of tickets issued
+	Journaled    int                            `json:"journaled,omitempty"`           //Sequence number of the last journal record contained in the snapshot
+	Events       []snapshotEvent                `json:"events"`                        //Parking, moving and leaving of cars in order of time
+}
+
+//snapshotCar is the on-disk format of a car
+type snapshotCar struct {
+	Slot         int           `json:"slot"`
+	Registration string        `json:"registration"`
+	Colour       string        `json:"colour"`
+	Class        string        `json:"class"`
+	Entry        time.Time     `json:"entry"`
+	Ticket       string        `json:"ticket"`
+	Gate         string        `json:"gate,omitempty"`
+	Required     string        `json:"required,omitempty"`
+	Preferred    string        `json:"preferred,omitempty"`
+	ChargeStart  time.Time     `json:"charge_start"`
+	ChargeTime   time.Duration `json:"charge_time"`
+	Energy       float64       `json:"energy"`
+	ChargeCost   int           `json:"charge_cost"`
+}
+
+//snapshotFormat is the on-disk format of the registration number format
+type snapshotFormat struct {
+	Format  string `json:"format"`
+	Pattern string `json:"pattern,omitempty"` //Pattern of a custom format
+}
+
+//snapshotAccessPoint is the on-disk format of an entrance or exit
+type snapshotAccessPoint struct {
+	Position int         `json:"position"`
+	Distance map[int]int `json:"distance"`
+}
+
+//snapshotReservation is the on-disk format of a reservation
+type snapshotReservation struct {
+	Registration string    `json:"registration"`
+	Slot         int       `json:"slot"`
+	Until        time.Time `json:"until"`
+}
+
+//snapshotCharger is the on-disk format of a charger
+type snapshotCharger struct {
+	Power    float64       `json:"power"`
+	Price    int           `json:"price"`
+	Since    time.Time     `json:"since"`
+	Sessions int           `json:"sessions"`
+	Busy     time.Duration `json:"busy"`
+	Energy   float64       `json:"energy"`
+}
+
+//snapshotWaitingList is the on-disk format of the waiting list
+type snapshotWaitingList struct {
+	Mode     string               `json:"mode"`
+	Arrivals int                  `json:"arrivals"`
+	Cars     []snapshotWaitingCar `json:"cars"` //Waiting cars in the order they are served
+}
+
+//snapshotWaitingCar is the on-disk format of a waiting car
+type snapshotWaitingCar struct {
+	Car      snapshotCar `json:"car"`
+	Priority int         `json:"priority"`
+	Arrival  int         `json:"arrival"`
+}
+
+//snapshotEvent is the on-disk format of an event of the event log
+type snapshotEvent struct {
+	Kind         string    `json:"kind"`
+	Time         time.Time `json:"time"`
+	Slot         int       `json:"slot"`
+	Registration string    `json:"registration"`
+	Colour       string    `json:"colour"`
+	Ticket       string    `json:"ticket"`
+}
+
+//Save the full state of the carpark to a file, replacing the file only once the snapshot is completely written
+func (carpark *Carpark) save(filename string) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	carpark.pruneEvents()
+	state, err := carpark.snapshot()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data)
+}
+
+//writeFileAtomic writes data to a temporary file which is synced and renamed over the given file
+func writeFileAtomic(filename string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filename)
+}
+
+//Capture the full state of the carpark
+func (carpark *Carpark) snapshot() (*snapshot, error) {
+	state := &snapshot{
+		Version:     snapshotVersion,
+		Floors:      carpark.floors,
+		MaxSlot:     carpark.maxSlot,
+		HighestSlot: carpark.highestSlot,
+		EmptySlots:  make(map[string][]int),
+		SlotClasses: make(map[int]string),
+		Tariff:      carpark.tariff,
+		Synonyms:    carpark.synonyms,
+		Entrances:   make(map[string]snapshotAccessPoint),
+		Exits:       make(map[string]snapshotAccessPoint),
+		SlotUses:    carpark.slotUses,
+		Attributes:  make(map[int]string),
+		Exclusive:   carpark.exclusive.String(),
+		Chargers:    make(map[int]snapshotCharger),
+		TicketNo:    carpark.ticketNo,
+		Journaled:   carpark.journaled,
+	}
+	for class, heap := range carpark.emptySlot {
+		if slots := heap.Values(); len(slots) > 0 {
+			state.EmptySlots[VehicleClass(class).String()] = slots
+		}
+	}
+	for _, car := range carpark.getStatus() {
+		state.Cars = append(state.Cars, newSnapshotCar(car))
+	}
+	for slotNo, class := range carpark.slotClass {
+		state.SlotClasses[slotNo] = class.String()
+	}
+	for slotNo := range carpark.closed {
+		state.Closed = append(state.Closed, slotNo)
+	}
+	sort.Ints(state.Closed)
+	if clock, ok := carpark.clock.(*manualClock); ok {
+		state.Clock = &clock.now
+	}
+	switch validator := carpark.validator.(type) {
+	case nil:
+	case *regexValidator:
+		state.Registration = &snapshotFormat{Format: validator.format}
+		if validator.format == "regex" {
+			state.Registration.Pattern = validator.pattern.String()
+		}
+	default:
+		return nil, errors.New("Cannot save a custom registration number validator")
+	}
+	switch strategy := carpark.strategy.(type) {
+	case nil:
+	case nearestStrategy:
+		state.Strategy = []string{"nearest"}
+	case exitStrategy:
+		state.Strategy = []string{"exit", strategy.exit}
+	case fillByFloorStrategy:
+		state.Strategy = []string{"fill_by_floor"}
+	case spreadStrategy:
+		state.Strategy = []string{"spread"}
+	case randomStrategy:
+		state.Strategy = []string{"random", strconv.FormatInt(strategy.seed, 10)}
+		state.RandomDraws = strategy.source.draws
+	default:
+		return nil, errors.New("Cannot save a custom allocation strategy")
+	}
+	for name, point := range carpark.entrances {
+		state.Entrances[name] = snapshotAccessPoint{Position: point.position, Distance: point.distance}
+	}
+	for name, point := range carpark.exits {
+		state.Exits[name] = snapshotAccessPoint{Position: point.position, Distance: point.distance}
+	}
+	for _, booking := range carpark.expiries.Values() {
+		state.Reservations = append(state.Reservations, snapshotReservation{Registration: booking.registration, Slot: booking.slot, Until: booking.until})
+	}
+	for slotNo, attributes := range carpark.attributes {
+		state.Attributes[slotNo] = attributes.String()
+	}
+	for slotNo, point := range carpark.chargers {
+		state.Chargers[slotNo] = snapshotCharger{Power: point.power, Price: point.price, Since: point.since, Sessions: point.sessions, Busy: point.busy, Energy: point.energy}
+	}
+	if carpark.waiting != nil {
+		state.Waiting = &snapshotWaitingList{Mode: carpark.waiting.mode, Arrivals: carpark.waiting.arrivals}
+		for _, waiting := range carpark.waitingCars() {
+			state.Waiting.Cars = append(state.Waiting.Cars, snapshotWaitingCar{Car: newSnapshotCar(waiting.car), Priority: waiting.priority, Arrival: waiting.arrival})
+		}
+	}
+	for _, event := range carpark.events {
+		state.Events = append(state.Events, snapshotEvent{Kind: event.kind.String(), Time: event.time, Slot: event.slot, Registration: event.registration, Colour: event.colour, Ticket: event.ticket})
+	}
+	return state, nil
+}
+
+//newSnapshotCar captures a car in the on-disk format
+func newSnapshotCar(car *Car) snapshotCar {
+	return snapshotCar{
+		Slot:         car.slot,
+		Registration: car.registration,
+		Colour:       car.colour,
+		Class:        car.class.String(),
+		Entry:        car.entry,
+		Ticket:       car.ticket,
+		Gate:         car.gate,
+		Required:     car.required.String(),
+		Preferred:    car.preferred.String(),
+		ChargeStart:  car.chargeStart,
+		ChargeTime:   car.chargeTime,
+		Energy:       car.energy,
+		ChargeCost:   car.chargeCost,
+	}
+}
+
+//loadCarpark reads the full state of a carpark from a snapshot file
+func loadCarpark(filename string) (*Carpark, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	state := &snapshot{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("Invalid snapshot: %v", err)
+	}
+	if state.Version != snapshotVersion {
+		return nil, fmt.Errorf("Unsupported snapshot version %v", state.Version)
+	}
+	carpark, err := state.restore()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid snapshot: %v", err)
+	}
+	return carpark, nil
+}
+
+//restore rebuilds a carpark from its snapshot
+func (state *snapshot) restore() (*Carpark, error) {
+	carpark := &Carpark{}
+	if err := carpark.init(state.Floors...); err != nil {
+		return nil, err
+	}
+	if carpark.maxSlot != state.MaxSlot || state.HighestSlot < 0 || state.HighestSlot > state.MaxSlot {
+		return nil, errors.New("slot counts do not match the floors")
+	}
+	carpark.highestSlot = state.HighestSlot
+	carpark.ticketNo = state.TicketNo
+	carpark.journaled = state.Journaled
+	for name, slots := range state.EmptySlots {
+		class, err := parseVehicleClass(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, slotNo := range slots {
+			carpark.emptySlot[class].Push(slotNo)
+		}
+	}
+	for _, saved := range state.Cars {
+		car, err := saved.restore()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := carpark.Map[car.slot]; ok || car.slot < 1 || car.slot > carpark.maxSlot {
+			return nil, fmt.Errorf("invalid slot of car %v", car.registration)
+		}
+		carpark.Map[car.slot] = car
+	}
+	for slotNo, name := range state.SlotClasses {
+		class, err := parseVehicleClass(name)
+		if err != nil {
+			return nil, err
+		}
+		carpark.slotClass[slotNo] = class
+	}
+	for _, slotNo := range state.Closed {
+		carpark.closed[slotNo] = true
+	}
+	if state.Clock != nil {
+		carpark.clock = &manualClock{now: *state.Clock}
+	}
+	if state.Tariff != nil {
+		if err := state.Tariff.validate(); err != nil {
+			return nil, err
+		}
+		carpark.tariff = state.Tariff
+	}
+	for colour, synonym := range state.Synonyms {
+		carpark.synonyms[colour] = synonym
+	}
+	if state.Registration != nil {
+		validator, err := newRegistrationValidator(state.Registration.Format, state.Registration.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		carpark.validator = validator
+	}
+	for name, point := range state.Entrances {
+		carpark.entrances[name] = point.restore()
+	}
+	for name, point := range state.Exits {
+		carpark.exits[name] = point.restore()
+	}
+	if len(state.Strategy) > 0 {
+		strategy, err := carpark.newAllocationStrategy(state.Strategy[0], state.Strategy[1:]...)
+		if err != nil {
+			return nil, err
+		}
+		carpark.strategy = strategy
+	}
+	if state.RandomDraws != 0 {
+		random, ok := carpark.strategy.(randomStrategy)
+		if !ok || state.RandomDraws < 0 {
+			return nil, fmt.Errorf("invalid random draws %v", state.RandomDraws)
+		}
+		random.source.rewind(state.RandomDraws)
+	}
+	for slotNo, uses := range state.SlotUses {
+		carpark.slotUses[slotNo] = uses
+	}
+	for _, saved := range state.Reservations {
+		carpark.addReservation(&reservation{registration: saved.Registration, slot: saved.Slot, until: saved.Until})
+	}
+	for slotNo, names := range state.Attributes {
+		attributes, err := parseSlotAttributes(names)
+		if err != nil {
+			return nil, err
+		}
+		carpark.attributes[slotNo] = attributes
+	}
+	if state.Exclusive != "" {
+		exclusive, err := parseSlotAttributes(state.Exclusive)
+		if err != nil {
+			return nil, err
+		}
+		carpark.exclusive = exclusive
+	}
+	for slotNo, saved := range state.Chargers {
+		carpark.chargers[slotNo] = &charger{power: saved.Power, price: saved.Price, since: saved.Since, sessions: saved.Sessions, busy: saved.Busy, energy: saved.Energy}
+	}
+	if state.Waiting != nil {
+		waiting, err := newWaitingList(state.Waiting.Mode)
+		if err != nil {
+			return nil, err
+		}
+		waiting.arrivals = state.Waiting.Arrivals
+		for _, saved := range state.Waiting.Cars {
+			car, err := saved.Car.restore()
+			if err != nil {
+				return nil, err
+			}
+			entry := &waitingCar{car: car, priority: saved.Priority, arrival: saved.Arrival}
+			waiting.queue.Push(entry)
+			waiting.regs[normalizeRegistration(car.registration)] = entry
+		}
+		carpark.waiting = waiting
+	}
+	for _, saved := range state.Events {
+		kind, err := parseEventKind(saved.Kind)
+		if err != nil {
+			return nil, err
+		}
+		carpark.events = append(carpark.events, &event{kind: kind, time: saved.Time, slot: saved.Slot, registration: saved.Registration, colour: saved.Colour, ticket: saved.Ticket})
+	}
+	if err := carpark.checkRestored(); err != nil {
+		return nil, err
+	}
+	carpark.reindex()
+	return carpark, nil
+}
+
+//Check that the empty slots and charging cars of a restored carpark agree with its slots and chargers
+func (carpark *Carpark) checkRestored() error {
+	for class, heap := range carpark.emptySlot {
+		for _, slotNo := range heap.Values() {
+			_, parked := carpark.Map[slotNo]
+			_, reserved := carpark.reserved[slotNo]
+			if parked || reserved || carpark.closed[slotNo] || slotNo < 1 || slotNo > carpark.highestSlot {
+				return fmt.Errorf("slot %v cannot be empty", slotNo)
+			}
+			if carpark.slotClass[slotNo] != VehicleClass(class) {
+				return fmt.Errorf("empty slot %v is not a %v slot", slotNo, VehicleClass(class))
+			}
+		}
+	}
+	for slotNo, car := range carpark.Map {
+		if _, ok := carpark.chargers[slotNo]; !ok && !car.chargeStart.IsZero() {
+			return fmt.Errorf("car %v is charging without a charger", car.registration)
+		}
+	}
+	return nil
+}
+
+//restore rebuilds a car from its snapshot
+func (saved *snapshotCar) restore() (*Car, error) {
+	class, err := parseVehicleClass(saved.Class)
+	if err != nil {
+		return nil, err
+	}
+	car := &Car{
+		slot:         saved.Slot,
+		registration: saved.Registration,
+		colour:       saved.Colour,
+		class:        class,
+		entry:        saved.Entry,
+		ticket:       saved.Ticket,
+		gate:         saved.Gate,
+		chargeStart:  saved.ChargeStart,
+		chargeTime:   saved.ChargeTime,
+		energy:       saved.Energy,
+		chargeCost:   saved.ChargeCost,
+	}
+	if saved.Required != "" {
+		if car.required, err = parseSlotAttributes(saved.Required); err != nil {
+			return nil, err
+		}
+	}
+	if saved.Preferred != "" {
+		if car.preferred, err = parseSlotAttributes(saved.Preferred); err != nil {
+			return nil, err
+		}
+	}
+	return car, nil
+}
+
+//restore rebuilds an entrance or exit from its snapshot
+func (saved *snapshotAccessPoint) restore() *accessPoint {
+	point := &accessPoint{position: saved.Position, distance: make(map[int]int)}
+	for slotNo, distance := range saved.Distance {
+		point.distance[slotNo] = distance
+	}
+	return point
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//busyCarpark returns a carpark using every feature which is saved in snapshots
+func busyCarpark(t *testing.T) *Carpark {
+	now := values().now
+	carpark := &Carpark{clock: &manualClock{now: now}}
+	steps := []error{carpark.init(3, 4)}
+	tariff, err := loadTariff("tariff.json")
+	steps = append(steps, err)
+	carpark.tariff = tariff
+	carpark.validator, err = newRegistrationValidator("regex", `^KA`)
+	steps = append(steps, err,
+		carpark.addColourSynonym("Crimson", "Red"),
+		carpark.setSlotClass(2, motorcycleClass),
+		carpark.closeSlot(7),
+		carpark.addEntrance("B", 6),
+		carpark.addExit("C", 1),
+		carpark.setDistance("B", 3, 0),
+		carpark.setSlotAttributes(4, accessibleAttribute|vipAttribute, true),
+		carpark.setAttributeRule(vipAttribute, "never"),
+		carpark.setCharger(5, 7.4, 30),
+		carpark.setWaitingList("priority"),
+	)
+	carpark.strategy, err = carpark.newAllocationStrategy("random", "7")
+	steps = append(steps, err)
+	for _, car := range []*Car{
+		{registration: "KA-01-HH-1234", colour: "White"},
+		{registration: "KA-01-HH-9999", colour: "Crimson", gate: "B"},
+		{registration: "KA-01-BB-0001", colour: "Black", required: evAttribute},
+		{registration: "KA-01-HH-7777", colour: "Red", class: motorcycleClass},
+	} {
+		_, err := carpark.insertCar(car)
+		steps = append(steps, err)
+	}
+	_, err = carpark.startCharge(5)
+	steps = append(steps, err)
+	_, err = carpark.removeCar(3)
+	steps = append(steps, err)
+	_, err = carpark.reserve("KA-01-HH-2701", 0, now.Add(time.Hour))
+	steps = append(steps, err)
+	_, err = carpark.enqueue(&Car{registration: "KA-01-HH-3141", colour: "Black"}, 2)
+	steps = append(steps, err)
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("step %v error = %v", i, err)
+		}
+	}
+	return carpark
+}
+
+func TestCarpark_save(t *testing.T) {
+	dir := t.TempDir()
+	carpark := busyCarpark(t)
+	if err := carpark.save(filepath.Join(dir, "first.json")); err != nil {
+		t.Fatalf("Carpark.save() error = %v", err)
+	}
+	restored, err := loadCarpark(filepath.Join(dir, "first.json"))
+	if err != nil {
+		t.Fatalf("loadCarpark() error = %v", err)
+	}
+	compareCarpark(t, restored, carpark)
+
+	//Saving the restored carpark writes the same snapshot
+	if err := restored.save(filepath.Join(dir, "second.json")); err != nil {
+		t.Fatalf("Carpark.save() error = %v", err)
+	}
+	first, _ := os.ReadFile(filepath.Join(dir, "first.json"))
+	second, _ := os.ReadFile(filepath.Join(dir, "second.json"))
+	if string(first) != string(second) {
+		t.Errorf("restored snapshot = %s, want %s", second, first)
+	}
+
+	//The restored carpark allocates the same slots
+	for _, registration := range []string{"KA-01-HH-0001", "KA-01-HH-0002"} {
+		want, wantErr := carpark.insertCar(&Car{registration: registration, colour: "Blue"})
+		got, err := restored.insertCar(&Car{registration: registration, colour: "Blue"})
+		if got != want || (err != nil) != (wantErr != nil) {
+			t.Errorf("restored Carpark.insertCar() = %v, %v, want %v, %v", got, err, want, wantErr)
+		}
+	}
+	if got, want := restored.waitingCars()[0].car, carpark.waitingCars()[0].car; !reflect.DeepEqual(got, want) {
+		t.Errorf("restored waiting car = %v, want %v", got, want)
+	}
+
+	//The restored random strategy resumes the same sequence
+	candidates := make([]int, 1000)
+	for i := range candidates {
+		candidates[i] = i + 1
+	}
+	for i := 0; i < 10; i++ {
+		if got, want := restored.strategy.Choose(restored, candidates), carpark.strategy.Choose(carpark, candidates); got != want {
+			t.Errorf("restored AllocationStrategy.Choose() = %v, want %v", got, want)
+		}
+	}
+}
+
+func Test_loadCarpark(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  bool
+	}{
+		{name: "Empty carpark",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2}`,
+			wantErr:  false,
+		},
+		{name: "Slots emptied",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "highest_slot": 2, "empty_slots": {"car": [1, 2]}}`,
+			wantErr:  false,
+		},
+		{name: "Random draws resumed",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "strategy": ["random", "7"], "random_draws": 3}`,
+			wantErr:  false,
+		},
+		{name: "Random draws without a random strategy",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "strategy": ["spread"], "random_draws": 3}`,
+			wantErr:  true,
+		},
+		{name: "Negative random draws",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "strategy": ["random", "7"], "random_draws": -3}`,
+			wantErr:  true,
+		},
+		{name: "Unsupported version",
+			contents: `{"version": 2, "floors": [2], "max_slot": 2}`,
+			wantErr:  true,
+		},
+		{name: "Truncated file",
+			contents: `{"version": 1, "floors": [2], "max_sl`,
+			wantErr:  true,
+		},
+		{name: "Slot counts not matching the floors",
+			contents: `{"version": 1, "floors": [2], "max_slot": 3}`,
+			wantErr:  true,
+		},
+		{name: "Car beyond the last slot",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "highest_slot": 2, "cars": [{"slot": 3, "class": "car"}]}`,
+			wantErr:  true,
+		},
+		{name: "Occupied slot among the empty slots",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "highest_slot": 2, "empty_slots": {"car": [1]}, "cars": [{"slot": 1, "class": "car"}]}`,
+			wantErr:  true,
+		},
+		{name: "Closed slot among the empty slots",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "highest_slot": 2, "empty_slots": {"car": [2]}, "closed": [2]}`,
+			wantErr:  true,
+		},
+		{name: "Reserved slot among the empty slots",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "highest_slot": 2, "empty_slots": {"car": [2]}, "reservations": [{"registration": "KA-01-HH-1234", "slot": 2, "until": "2019-01-01T09:00:00Z"}]}`,
+			wantErr:  true,
+		},
+		{name: "Empty slot above the highest slot",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "highest_slot": 1, "empty_slots": {"car": [2]}}`,
+			wantErr:  true,
+		},
+		{name: "Empty slot in the heap of another class",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "highest_slot": 2, "empty_slots": {"van": [2]}}`,
+			wantErr:  true,
+		},
+		{name: "Charging car without a charger",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "highest_slot": 1, "cars": [{"slot": 1, "class": "car", "charge_start": "2019-01-01T08:00:00Z"}]}`,
+			wantErr:  true,
+		},
+		{name: "Charging car with a charger",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "highest_slot": 1, "cars": [{"slot": 1, "class": "car", "charge_start": "2019-01-01T08:00:00Z"}], "chargers": {"1": {"power": 7, "price": 30}}}`,
+			wantErr:  false,
+		},
+		{name: "Unknown vehicle class",
+			contents: `{"version": 1, "floors": [2], "max_slot": 2, "highest_slot": 1, "cars": [{"slot": 1, "class": "tank"}]}`,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, "state.json")
+			if err := os.WriteFile(filename, []byte(tt.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadCarpark(filename); (err != nil) != tt.wantErr {
+				t.Errorf("loadCarpark() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := loadCarpark(filepath.Join(dir, "missing.json")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("loadCarpark() of a missing file error = %v, want %v", err, fs.ErrNotExist)
+	}
+}
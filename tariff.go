@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+//Tariff represents the parking charges, with optional rates for specific vehicle classes
+type Tariff struct {
+	Default Rates            `json:"default"` //Rates applied to vehicle classes without rates of their own
+	Classes map[string]Rates `json:"classes"` //Rates of each vehicle class, keyed by class name
+}
+
+//Rates represents the parking charges, in cents, of a vehicle class
+type Rates struct {
+	FreeMinutes int        `json:"free_minutes"` //Stays no longer than this are free of charge
+	Bands       []Band     `json:"bands"`        //Hourly rates, in ascending order of the hour of stay they end at
+	DailyCap    int        `json:"daily_cap"`    //Maximum charge for each 24 hours of stay, or zero for no cap
+	Night       *NightRate `json:"night"`        //Hourly rate for hours starting at night, or nil for no night rate
+}
+
+//Band represents the rate charged for each hour of stay started before the band ends
+type Band struct {
+	UpTo int `json:"up_to"` //Hour of stay, counted within each 24 hours, at which the band ends, or zero for no end
+	Rate int `json:"rate"`  //Charge for each hour started within the band
+}
+
+//NightRate represents the rate charged for each hour of stay started at night
+type NightRate struct {
+	Start string `json:"start"` //Time of day at which the night starts, such as 22:00
+	End   string `json:"end"`   //Time of day at which the night ends, such as 06:00
+	Rate  int    `json:"rate"`  //Charge for each hour started at night
+	start int    //Minute of the day at which the night starts
+	end   int    //Minute of the day at which the night ends
+}
+
+//loadTariff reads a tariff from a JSON file
+func loadTariff(filename string) (*Tariff, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	tariff := &Tariff{}
+	if err := decoder.Decode(tariff); err != nil {
+		return nil, fmt.Errorf("Invalid tariff: %v", err)
+	}
+	if err := tariff.validate(); err != nil {
+		return nil, err
+	}
+	return tariff, nil
+}
+
+//validate verifies the rates of every vehicle class and parses their night times
+func (tariff *Tariff) validate() error {
+	if err := tariff.Default.validate(); err != nil {
+		return err
+	}
+	for name, rates := range tariff.Classes {
+		if _, err := parseVehicleClass(name); err != nil {
+			return fmt.Errorf("Invalid tariff: %v", err)
+		}
+		if err := rates.validate(); err != nil {
+			return err
+		}
+		tariff.Classes[name] = rates
+	}
+	return nil
+}
+
+//rates retrieves the rates charged to a vehicle class
+func (tariff *Tariff) rates(class VehicleClass) *Rates {
+	if rates, ok := tariff.Classes[class.String()]; ok {
+		return &rates
+	}
+	return &tariff.Default
+}
+
+//validate verifies the rates and parses the night times
+func (rates *Rates) validate() error {
+	if rates.FreeMinutes < 0 || rates.DailyCap < 0 {
+		return errors.New("Invalid tariff: negative charge")
+	}
+	for i, band := range rates.Bands {
+		if band.Rate < 0 {
+			return errors.New("Invalid tariff: negative charge")
+		}
+		if i > 0 && (band.UpTo != 0 && band.UpTo <= rates.Bands[i-1].UpTo || rates.Bands[i-1].UpTo == 0) {
+			return errors.New("Invalid tariff: bands not in ascending order")
+		}
+	}
+	if night := rates.Night; night != nil {
+		start, err := time.Parse("15:04", night.Start)
+		if err != nil {
+			return fmt.Errorf("Invalid tariff: %v", err)
+		}
+		end, err := time.Parse("15:04", night.End)
+		if err != nil {
+			return fmt.Errorf("Invalid tariff: %v", err)
+		}
+		if night.Rate < 0 {
+			return errors.New("Invalid tariff: negative charge")
+		}
+		night.start = start.Hour()*60 + start.Minute()
+		night.end = end.Hour()*60 + end.Minute()
+	}
+	return nil
+}
+
+//fee computes the charge of a stay from entry until exit
+func (rates *Rates) fee(entry, exit time.Time) int {
+	stay := exit.Sub(entry)
+	if stay <= time.Duration(rates.FreeMinutes)*time.Minute {
+		return 0
+	}
+	//Charge each started hour, capping the charge of every 24 hours of stay
+	hours := int((stay + time.Hour - 1) / time.Hour)
+	total, day := 0, 0
+	for hour := 0; hour < hours; hour++ {
+		if hour > 0 && hour%24 == 0 {
+			total += rates.cap(day)
+			day = 0
+		}
+		day += rates.hourlyRate(hour%24, entry.Add(time.Duration(hour)*time.Hour))
+	}
+	return total + rates.cap(day)
+}
+
+//hourlyRate retrieves the rate of an hour of stay, given its position within the day of stay and its start time
+func (rates *Rates) hourlyRate(hour int, start time.Time) int {
+	if night := rates.Night; night != nil && night.covers(start) {
+		return night.Rate
+	}
+	for _, band := range rates.Bands {
+		if band.UpTo == 0 || hour < band.UpTo {
+			return band.Rate
+		}
+	}
+	if len(rates.Bands) == 0 {
+		return 0
+	}
+	return rates.Bands[len(rates.Bands)-1].Rate
+}
+
+//cap limits the charge of a day of stay to the daily cap
+func (rates *Rates) cap(charge int) int {
+	if rates.DailyCap > 0 && charge > rates.DailyCap {
+		return rates.DailyCap
+	}
+	return charge
+}
+
+//covers verifies whether a time of day falls within the night, which may span midnight
+func (night *NightRate) covers(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if night.start <= night.end {
+		return night.start <= minute && minute < night.end
+	}
+	return minute >= night.start || minute < night.end
+}
+
+//formatAmount prints an amount in cents as a decimal amount, such as 12.50
+func formatAmount(cents int) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
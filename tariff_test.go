@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRates_fee(t *testing.T) {
+	tariff, err := loadTariff("tariff.json")
+	if err != nil {
+		t.Fatalf("loadTariff() error = %v", err)
+	}
+	entry := time.Date(2019, 1, 1, 8, 0, 0, 0, time.UTC)
+	type args struct {
+		class VehicleClass
+		entry time.Time
+		stay  time.Duration
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{name: "Free first minutes",
+			args: args{class: carClass, entry: entry, stay: 15 * time.Minute},
+			want: 0,
+		},
+		{name: "Hourly bands",
+			args: args{class: carClass, entry: entry, stay: 150 * time.Minute},
+			want: 700,
+		},
+		{name: "Night rate",
+			args: args{class: carClass, entry: entry.Add(13*time.Hour + 30*time.Minute), stay: 2 * time.Hour},
+			want: 350,
+		},
+		{name: "Daily cap",
+			args: args{class: carClass, entry: entry, stay: 30 * time.Hour},
+			want: 3000,
+		},
+		{name: "Vehicle class rates",
+			args: args{class: motorcycleClass, entry: entry, stay: 2 * time.Hour},
+			want: 100,
+		},
+		{name: "Vehicle class rates without free minutes",
+			args: args{class: busClass, entry: entry, stay: 20 * time.Minute},
+			want: 1000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates := tariff.rates(tt.args.class)
+			if got := rates.fee(tt.args.entry, tt.args.entry.Add(tt.args.stay)); got != tt.want {
+				t.Errorf("Rates.fee() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRates_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rates   Rates
+		wantErr bool
+	}{
+		{name: "Valid rates",
+			rates:   Rates{Bands: []Band{{UpTo: 1, Rate: 300}, {Rate: 100}}, Night: &NightRate{Start: "22:00", End: "06:00", Rate: 50}},
+			wantErr: false,
+		},
+		{name: "Bands not in ascending order",
+			rates:   Rates{Bands: []Band{{UpTo: 3, Rate: 300}, {UpTo: 1, Rate: 100}}},
+			wantErr: true,
+		},
+		{name: "Band after open ended band",
+			rates:   Rates{Bands: []Band{{Rate: 300}, {UpTo: 5, Rate: 100}}},
+			wantErr: true,
+		},
+		{name: "Negative rate",
+			rates:   Rates{Bands: []Band{{Rate: -300}}},
+			wantErr: true,
+		},
+		{name: "Malformed night time",
+			rates:   Rates{Night: &NightRate{Start: "10pm", End: "06:00", Rate: 50}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rates.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Rates.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
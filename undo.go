@@ -0,0 +1,375 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+//undoDepth is the number of commands which can be undone
+const undoDepth = 20
+
+//change is a change to the carpark which can be reverted, and applied again once reverted
+type change interface {
+	revert(carpark *Carpark)
+	reapply(carpark *Carpark) error
+}
+
+//frontier is the highest slot filled before a slot was allocated, with the slots passed over by the allocation,
+//which were pushed into the heaps of empty slots, and the numbers drawn by the random strategy
+type frontier struct {
+	highest int
+	passed  []int
+	source  *countingSource //Source of the random strategy, which is nil under other strategies
+	draws   int64           //Numbers drawn from the source before the allocation
+	drawn   int64           //Numbers drawn from the source after the allocation
+}
+
+//parking is a car parked in the carpark
+type parking struct {
+	car      *Car
+	slot     int          //Slot the car was parked at
+	entry    time.Time    //Time at which the car was parked
+	frontier frontier     //Highest slot filled before the car was parked, and the slots passed over
+	booking  *reservation //Reservation of the slot taken by the car, which is nil when the car had none
+}
+
+//departure is a car which left the carpark
+type departure struct {
+	car           *Car
+	before        Car       //Car as it was before it left
+	exit          time.Time //Time at which the car left
+	charger       *charger  //Charger of the slot of a car which was charging, which is nil otherwise
+	chargerBefore charger   //Charger as it was before the car left
+}
+
+//relocation is a car moved to an empty slot
+type relocation struct {
+	car      *Car
+	from     int
+	to       int
+	frontier frontier  //Highest slot filled before the car was moved, and the slots passed over
+	time     time.Time //Time at which the car was moved
+}
+
+//exchange is the swap of the cars parked in two slots
+type exchange struct {
+	a    int
+	b    int
+	time time.Time //Time at which the cars were swapped
+}
+
+//lowering is the highest slot filled lowered below the empty slots at the top
+type lowering struct {
+	highest int   //Highest slot filled before it was lowered
+	slots   []int //Empty slots withdrawn from the heaps of empty slots
+}
+
+//admission is a car taken off the waiting list, as it was parked or could never be parked
+type admission struct {
+	entry *waitingCar
+}
+
+//queueing is a car added to the waiting list
+type queueing struct {
+	entry *waitingCar
+}
+
+//Record a change to the carpark, if changes are tracked
+func (carpark *Carpark) track(change change) {
+	if carpark.tracking {
+		carpark.changes = append(carpark.changes, change)
+	}
+}
+
+//Retrieve the changes tracked since the changes were last taken
+func (carpark *Carpark) takeChanges() []change {
+	changes := carpark.changes
+	carpark.changes = nil
+	return changes
+}
+
+func (parking *parking) revert(carpark *Carpark) {
+	registration := parking.car.registration
+	carpark.unpark(parking.car, parking.frontier, parking.booking)
+	record := journalRecord{Op: "unpark", Time: carpark.now(), Slot: parking.slot, Registration: registration, Frontier: newJournalFrontier(parking.frontier)}
+	if parking.booking != nil {
+		record.Reservation = &snapshotReservation{Registration: parking.booking.registration, Slot: parking.booking.slot, Until: parking.booking.until}
+	}
+	//A journal which cannot be written stops the carpark once the undo completes
+	carpark.record(record)
+}
+
+func (parking *parking) reapply(carpark *Carpark) error {
+	return carpark.at(parking.entry, func() error {
+		if parking.booking != nil {
+			carpark.dropReservation(parking.booking)
+		} else {
+			carpark.claimSlot(parking.slot)
+		}
+		if parking.frontier.source != nil {
+			parking.frontier.source.rewind(parking.frontier.drawn)
+		}
+		carpark.park(parking.car, parking.slot)
+		saved := newSnapshotCar(parking.car)
+		return carpark.record(journalRecord{Op: "park", Time: parking.entry, Car: &saved})
+	})
+}
+
+func (departure *departure) revert(carpark *Carpark) {
+	carpark.unremoveCar(departure.car, departure.before, departure.charger, departure.chargerBefore)
+	saved := newSnapshotCar(departure.car)
+	record := journalRecord{Op: "unleave", Time: carpark.now(), Car: &saved}
+	if point := departure.chargerBefore; departure.charger != nil {
+		record.Charger = &snapshotCharger{Power: point.power, Price: point.price, Since: point.since, Sessions: point.sessions, Busy: point.busy, Energy: point.energy}
+	}
+	carpark.record(record)
+}
+
+func (departure *departure) reapply(carpark *Carpark) error {
+	return carpark.at(departure.exit, func() error {
+		_, err := carpark.removeCar(departure.car.slot)
+		return err
+	})
+}
+
+func (relocation *relocation) revert(carpark *Carpark) {
+	carpark.unmoveCar(relocation.car, relocation.from, relocation.to, relocation.frontier)
+	carpark.record(journalRecord{Op: "unmove", Time: carpark.now(), Slot: relocation.from, To: relocation.to, Frontier: newJournalFrontier(relocation.frontier)})
+}
+
+func (relocation *relocation) reapply(carpark *Carpark) error {
+	return carpark.at(relocation.time, func() error {
+		_, err := carpark.moveCar(relocation.from, relocation.to)
+		return err
+	})
+}
+
+func (exchange *exchange) revert(carpark *Carpark) {
+	carpark.unswapCars(exchange.a, exchange.b)
+	carpark.record(journalRecord{Op: "unswap", Time: carpark.now(), Slot: exchange.a, To: exchange.b})
+}
+
+func (exchange *exchange) reapply(carpark *Carpark) error {
+	return carpark.at(exchange.time, func() error {
+		return carpark.swapCars(exchange.a, exchange.b)
+	})
+}
+
+func (lowering *lowering) revert(carpark *Carpark) {
+	for _, slotNo := range lowering.slots {
+		carpark.emptySlot[carpark.slotClass[slotNo]].Push(slotNo)
+	}
+	carpark.highestSlot = lowering.highest
+}
+
+func (lowering *lowering) reapply(carpark *Carpark) error {
+	carpark.lowerHighestSlot()
+	return nil
+}
+
+func (admission *admission) revert(carpark *Carpark) {
+	carpark.waiting.queue.Push(admission.entry)
+	carpark.waiting.regs[normalizeRegistration(admission.entry.car.registration)] = admission.entry
+}
+
+func (admission *admission) reapply(carpark *Carpark) error {
+	carpark.waiting.queue.Remove(admission.entry)
+	delete(carpark.waiting.regs, normalizeRegistration(admission.entry.car.registration))
+	return nil
+}
+
+func (queueing *queueing) revert(carpark *Carpark) {
+	carpark.waiting.queue.Remove(queueing.entry)
+	delete(carpark.waiting.regs, normalizeRegistration(queueing.entry.car.registration))
+	carpark.waiting.arrivals--
+}
+
+func (queueing *queueing) reapply(carpark *Carpark) error {
+	carpark.waiting.arrivals++
+	carpark.waiting.queue.Push(queueing.entry)
+	carpark.waiting.regs[normalizeRegistration(queueing.entry.car.registration)] = queueing.entry
+	return nil
+}
+
+//Take the last car parked out of the carpark, returning its slot to the empty slots or to its reservation
+func (carpark *Carpark) unpark(car *Car, before frontier, booking *reservation) {
+	slotNo := car.slot
+	delete(carpark.Map, slotNo)
+	delete(carpark.tickets, car.ticket)
+	delete(carpark.regs, normalizeRegistration(car.registration))
+	carpark.unindexColour(car)
+	carpark.unuseSlot(slotNo)
+	carpark.ticketNo--
+	if booking != nil {
+		carpark.addReservation(booking)
+	} else {
+		carpark.releaseSlot(slotNo, before)
+	}
+	carpark.popEvent()
+	//Forget the slot, entry and ticket of the car, which may go back to the waiting list
+	car.slot, car.entry, car.ticket = 0, time.Time{}, ""
+}
+
+//Put the last car which left back into its slot, as it was before it left
+func (carpark *Carpark) unremoveCar(car *Car, before Car, point *charger, pointBefore charger) {
+	*car = before
+	if point != nil {
+		*point = pointBefore
+	}
+	carpark.emptySlot[carpark.slotClass[car.slot]].Remove(car.slot)
+	carpark.place(car, car.slot)
+	carpark.indexColour(car)
+	carpark.popEvent()
+}
+
+//Move the last car moved back to the slot it was moved from
+func (carpark *Carpark) unmoveCar(car *Car, from int, to int, before frontier) {
+	carpark.emptySlot[carpark.slotClass[from]].Remove(from)
+	delete(carpark.Map, to)
+	carpark.unindexColour(car)
+	carpark.unuseSlot(to)
+	carpark.place(car, from)
+	carpark.indexColour(car)
+	carpark.releaseSlot(to, before)
+	carpark.popEvent()
+}
+
+//Swap the last cars swapped back into their slots
+func (carpark *Carpark) unswapCars(a int, b int) {
+	carA, carB := carpark.Map[b], carpark.Map[a]
+	carpark.unindexColour(carA)
+	carpark.unindexColour(carB)
+	carpark.place(carA, a)
+	carpark.place(carB, b)
+	carpark.unuseSlot(a)
+	carpark.unuseSlot(b)
+	carpark.indexColour(carA)
+	carpark.indexColour(carB)
+	carpark.popEvent()
+	carpark.popEvent()
+}
+
+//Retrieve the frontier before an allocation
+func (carpark *Carpark) frontier() frontier {
+	before := frontier{highest: carpark.highestSlot}
+	if random, ok := carpark.strategy.(randomStrategy); ok {
+		before.source, before.draws = random.source, random.source.draws
+	}
+	return before
+}
+
+//Complete the frontier before an allocation with the slots passed over and the numbers drawn, given the slot allocated
+func (carpark *Carpark) passedSince(before frontier, slotNo int) frontier {
+	for passed := before.highest + 1; passed <= carpark.highestSlot; passed++ {
+		if passed != slotNo && !carpark.closed[passed] {
+			before.passed = append(before.passed, passed)
+		}
+	}
+	if before.source != nil {
+		before.drawn = before.source.draws
+	}
+	return before
+}
+
+//Take the slots passed over by an allocation back out of the heaps, and lower the highest slot filled and rewind
+//the random strategy back
+func (carpark *Carpark) restoreFrontier(before frontier) {
+	for _, passed := range before.passed {
+		carpark.emptySlot[carpark.slotClass[passed]].Remove(passed)
+	}
+	carpark.highestSlot = before.highest
+	if before.source != nil {
+		before.source.rewind(before.draws)
+	}
+}
+
+//Return a slot taken from the empty slots, given the frontier before it was taken
+func (carpark *Carpark) releaseSlot(slotNo int, before frontier) {
+	//Put back a slot taken from the heaps, rather than above the highest slot filled
+	if slotNo <= before.highest {
+		carpark.emptySlot[carpark.slotClass[slotNo]].Push(slotNo)
+	}
+	carpark.restoreFrontier(before)
+}
+
+//Count one allocation of a slot less
+func (carpark *Carpark) unuseSlot(slotNo int) {
+	carpark.slotUses[slotNo]--
+	if carpark.slotUses[slotNo] <= 0 {
+		delete(carpark.slotUses, slotNo)
+	}
+}
+
+//Remove the last event from the event log
+func (carpark *Carpark) popEvent() {
+	carpark.events = carpark.events[:len(carpark.events)-1]
+}
+
+//command is an operator command whose changes to the carpark can be undone
+type command struct {
+	input   string   //Input line of the command
+	changes []change //Changes made by the command, in the order they were made
+}
+
+//undoHistory holds the last commands which can be undone, and the commands undone which can be redone
+type undoHistory struct {
+	depth  int        //Number of commands which can be undone
+	done   []*command //Commands which can be undone, with the last command at the end
+	undone []*command //Commands which can be redone, with the last command undone at the end
+}
+
+//newUndoHistory creates an empty history keeping the given number of commands
+func newUndoHistory(depth int) *undoHistory {
+	return &undoHistory{depth: depth}
+}
+
+//push adds a command to the history, forgetting the oldest command beyond the depth and the commands undone
+func (history *undoHistory) push(cmd *command) {
+	history.done = append(history.done, cmd)
+	if len(history.done) > history.depth {
+		history.done = history.done[1:]
+	}
+	history.undone = nil
+}
+
+//clear forgets every command, such as after a change to the carpark which cannot be undone
+func (history *undoHistory) clear() {
+	history.done = nil
+	history.undone = nil
+}
+
+//undo reverts the changes of the last command, in reverse order
+func (history *undoHistory) undo(carpark *Carpark) (*command, error) {
+	if len(history.done) == 0 {
+		return nil, errors.New("Nothing to undo")
+	}
+	cmd := history.done[len(history.done)-1]
+	history.done = history.done[:len(history.done)-1]
+	for i := len(cmd.changes) - 1; i >= 0; i-- {
+		cmd.changes[i].revert(carpark)
+	}
+	history.undone = append(history.undone, cmd)
+	return cmd, nil
+}
+
+//redo applies the changes of the last command undone again
+func (history *undoHistory) redo(carpark *Carpark) (*command, error) {
+	if len(history.undone) == 0 {
+		return nil, errors.New("Nothing to redo")
+	}
+	cmd := history.undone[len(history.undone)-1]
+	history.undone = history.undone[:len(history.undone)-1]
+	//Changes made while redoing are already part of the command
+	tracking := carpark.tracking
+	carpark.tracking = false
+	defer func() { carpark.tracking = tracking }()
+	for _, change := range cmd.changes {
+		if err := change.reapply(carpark); err != nil {
+			history.clear()
+			return nil, fmt.Errorf("Cannot redo %v: %v", cmd.input, err)
+		}
+	}
+	history.done = append(history.done, cmd)
+	return cmd, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+//savedState captures the full state of a carpark for comparison
+func savedState(t *testing.T, carpark *Carpark) string {
+	state, err := carpark.snapshot()
+	if err != nil {
+		t.Fatalf("Carpark.snapshot() error = %v", err)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func Test_change(t *testing.T) {
+	park := func(registration string, options ...string) func(*Carpark) error {
+		return func(carpark *Carpark) error {
+			car := &Car{registration: registration, colour: "White"}
+			if _, err := parseParkOptions(car, options); err != nil {
+				return err
+			}
+			_, err := carpark.insertCar(car)
+			return err
+		}
+	}
+	leave := func(slotNo int) func(*Carpark) error {
+		return func(carpark *Carpark) error {
+			_, err := carpark.removeCar(slotNo)
+			return err
+		}
+	}
+	tests := []struct {
+		name    string
+		setup   []func(*Carpark) error
+		command func(*Carpark) error
+	}{
+		{name: "Park a car in a new slot",
+			setup: []func(*Carpark) error{
+				func(carpark *Carpark) error { return carpark.setSlotClass(1, motorcycleClass) },
+			},
+			command: park("KA-01-HH-1234"),
+		},
+		{name: "Park a car in a slot which was occupied before",
+			setup:   []func(*Carpark) error{park("KA-01-HH-1234"), park("KA-01-HH-9999"), leave(1)},
+			command: park("KA-01-BB-0001"),
+		},
+		{name: "Park a car in its reserved slot",
+			setup: []func(*Carpark) error{
+				park("KA-01-HH-1234"),
+				func(carpark *Carpark) error {
+					_, err := carpark.reserve("KA-01-BB-0001", 3, values().now.Add(time.Hour))
+					return err
+				},
+			},
+			command: park("KA-01-BB-0001"),
+		},
+		{name: "Park a car through an entrance",
+			setup: []func(*Carpark) error{
+				func(carpark *Carpark) error { return carpark.addEntrance("B", 4) },
+			},
+			command: park("KA-01-HH-1234", "gate=B"),
+		},
+		{name: "Park a car at a slot chosen by the exit strategy",
+			setup: []func(*Carpark) error{
+				func(carpark *Carpark) error { return carpark.addExit("B", 4) },
+				func(carpark *Carpark) error {
+					strategy, err := carpark.newAllocationStrategy("exit", "B")
+					carpark.strategy = strategy
+					return err
+				},
+			},
+			command: park("KA-01-HH-1234"),
+		},
+		{name: "Park a car at a slot chosen by the random strategy",
+			setup: []func(*Carpark) error{
+				func(carpark *Carpark) error {
+					strategy, err := carpark.newAllocationStrategy("random", "7")
+					carpark.strategy = strategy
+					return err
+				},
+				park("KA-01-HH-1234"),
+			},
+			command: park("KA-01-HH-9999"),
+		},
+		{name: "Park a car on the waiting list",
+			setup: []func(*Carpark) error{
+				func(carpark *Carpark) error { return carpark.setWaitingList("fifo") },
+				park("KA-01-HH-1234"), park("KA-01-HH-9999"), park("KA-01-BB-0001"), park("KA-01-HH-7777"),
+			},
+			command: func(carpark *Carpark) error {
+				_, err := carpark.enqueue(&Car{registration: "KA-01-HH-2701", colour: "Blue"}, 0)
+				return err
+			},
+		},
+		{name: "Remove a car",
+			setup:   []func(*Carpark) error{park("KA-01-HH-1234"), park("KA-01-HH-9999")},
+			command: leave(1),
+		},
+		{name: "Remove a charging car",
+			setup: []func(*Carpark) error{
+				park("KA-01-HH-1234"),
+				func(carpark *Carpark) error { return carpark.setCharger(1, 7, 30) },
+				func(carpark *Carpark) error {
+					_, err := carpark.startCharge(1)
+					return err
+				},
+			},
+			command: leave(1),
+		},
+		{name: "Remove a car admitting a waiting car",
+			setup: []func(*Carpark) error{
+				func(carpark *Carpark) error { return carpark.setWaitingList("fifo") },
+				park("KA-01-HH-1234"), park("KA-01-HH-9999"), park("KA-01-BB-0001"), park("KA-01-HH-7777"),
+				func(carpark *Carpark) error {
+					_, err := carpark.enqueue(&Car{registration: "KA-01-HH-2701", colour: "Blue"}, 0)
+					return err
+				},
+			},
+			command: func(carpark *Carpark) error {
+				if _, err := carpark.removeCar(2); err != nil {
+					return err
+				}
+				carpark.admitWaiting()
+				return nil
+			},
+		},
+		{name: "Move a car to a new slot",
+			setup: []func(*Carpark) error{park("KA-01-HH-1234")},
+			command: func(carpark *Carpark) error {
+				_, err := carpark.moveCar(1, 3)
+				return err
+			},
+		},
+		{name: "Lower the highest slot",
+			setup: []func(*Carpark) error{park("KA-01-HH-1234"), park("KA-01-HH-9999"), park("KA-01-BB-0001"), leave(3), leave(2)},
+			command: func(carpark *Carpark) error {
+				carpark.lowerHighestSlot()
+				return nil
+			},
+		},
+		{name: "Swap cars",
+			setup:   []func(*Carpark) error{park("KA-01-HH-1234"), park("KA-01-HH-9999")},
+			command: func(carpark *Carpark) error { return carpark.swapCars(1, 2) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &manualClock{now: values().now}
+			carpark := &Carpark{clock: clock}
+			if err := carpark.init(4); err != nil {
+				t.Fatalf("Carpark.init() error = %v", err)
+			}
+			for i, step := range tt.setup {
+				if err := step(carpark); err != nil {
+					t.Fatalf("step %v error = %v", i, err)
+				}
+				clock.now = clock.now.Add(10 * time.Minute)
+			}
+			before := savedState(t, carpark)
+			carpark.tracking = true
+			if err := tt.command(carpark); err != nil {
+				t.Fatalf("command error = %v", err)
+			}
+			carpark.tracking = false
+			changes := carpark.takeChanges()
+			after := savedState(t, carpark)
+
+			//Revert and reapply the changes later, setting the clock back to compare the states
+			now := clock.now
+			clock.now = now.Add(10 * time.Minute)
+			for i := len(changes) - 1; i >= 0; i-- {
+				changes[i].revert(carpark)
+			}
+			clock.now = now
+			if got := savedState(t, carpark); got != before {
+				t.Errorf("reverted carpark = %v, want %v", got, before)
+			}
+			clock.now = now.Add(20 * time.Minute)
+			for _, change := range changes {
+				if err := change.reapply(carpark); err != nil {
+					t.Fatalf("change.reapply() error = %v", err)
+				}
+			}
+			clock.now = now
+			if got := savedState(t, carpark); got != after {
+				t.Errorf("reapplied carpark = %v, want %v", got, after)
+			}
+		})
+	}
+}
+
+func Test_undoHistory(t *testing.T) {
+	carpark := &Carpark{clock: &manualClock{now: values().now}, tracking: true}
+	if err := carpark.init(3); err != nil {
+		t.Fatalf("Carpark.init() error = %v", err)
+	}
+	carpark.takeChanges()
+	history := newUndoHistory(2)
+	for _, registration := range []string{"KA-01-HH-1234", "KA-01-HH-9999", "KA-01-BB-0001"} {
+		if _, err := carpark.insertCar(&Car{registration: registration, colour: "White"}); err != nil {
+			t.Fatalf("Carpark.insertCar() error = %v", err)
+		}
+		history.push(&command{input: "park " + registration, changes: carpark.takeChanges()})
+	}
+
+	//Only the last commands up to the depth of the history are undone
+	steps := []struct {
+		name      string
+		operation func(*Carpark) (*command, error)
+		wantInput string
+		wantErr   bool
+	}{
+		{name: "Redo before undo", operation: history.redo, wantErr: true},
+		{name: "Undo", operation: history.undo, wantInput: "park KA-01-BB-0001"},
+		{name: "Undo again", operation: history.undo, wantInput: "park KA-01-HH-9999"},
+		{name: "Undo beyond the depth", operation: history.undo, wantErr: true},
+		{name: "Redo", operation: history.redo, wantInput: "park KA-01-HH-9999"},
+	}
+	for _, step := range steps {
+		cmd, err := step.operation(carpark)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%v error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+		if err == nil && cmd.input != step.wantInput {
+			t.Errorf("%v = %v, want %v", step.name, cmd.input, step.wantInput)
+		}
+	}
+	if len(carpark.takeChanges()) != 0 {
+		t.Errorf("changes were tracked while redoing")
+	}
+	if got := carpark.getStatus(); len(got) != 2 || got[1].registration != "KA-01-HH-9999" {
+		t.Errorf("Carpark.getStatus() = %v, want KA-01-HH-1234 and KA-01-HH-9999", got)
+	}
+
+	//A new command forgets the commands undone
+	history.push(&command{input: "leave 1"})
+	if _, err := history.redo(carpark); err == nil {
+		t.Errorf("redo after a new command error = %v, wantErr true", err)
+	}
+
+}
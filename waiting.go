@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"minheap"
+)
+
+//waitingCar is a car waiting for a slot, served in order of priority and then of arrival
+type waitingCar struct {
+	car      *Car //Car which is waiting
+	priority int  //Priority of the car, where higher priorities are served first
+	arrival  int  //Order in which the car joined the waiting list
+}
+
+//waitingList holds the cars waiting for a slot when the carpark is full
+type waitingList struct {
+	mode     string                     //Order in which cars are served, either "fifo" or "priority"
+	queue    *minheap.Heap[*waitingCar] //Heap of waiting cars, with the next car to be served on top
+	regs     map[string]*waitingCar     //Waiting cars, keyed by normalized registration number
+	arrivals int                        //Number of cars which have joined the waiting list
+}
+
+//newWaitingList creates an empty waiting list serving cars in first-in first-out or priority order
+func newWaitingList(mode string) (*waitingList, error) {
+	if mode != "fifo" && mode != "priority" {
+		return nil, fmt.Errorf("Unknown waiting list %v", mode)
+	}
+	queue := minheap.New(func(a, b *waitingCar) bool {
+		if a.priority != b.priority {
+			return a.priority > b.priority
+		}
+		return a.arrival < b.arrival
+	})
+	return &waitingList{mode: mode, queue: queue, regs: make(map[string]*waitingCar)}, nil
+}
+
+//Set the order in which cars are served by the waiting list, or turn cars away when the carpark is full if mode is "off"
+func (carpark *Carpark) setWaitingList(mode string) error {
+	if err := carpark.initStatus(); err != nil {
+		return err
+	}
+	if carpark.waiting != nil && carpark.waiting.queue.Len() > 0 {
+		return errors.New("Waiting list is not empty")
+	}
+	if mode == "off" {
+		carpark.waiting = nil
+		return nil
+	}
+	waiting, err := newWaitingList(mode)
+	if err != nil {
+		return err
+	}
+	carpark.waiting = waiting
+	return nil
+}
+
+//Add a car to the waiting list with the given priority, which is ignored by a first-in first-out list, and
+//return its position in the list
+func (carpark *Carpark) enqueue(car *Car, priority int) (int, error) {
+	if carpark.waiting == nil {
+		return 0, errors.New("No waiting list")
+	}
+	reg := normalizeRegistration(car.registration)
+	if _, ok := carpark.waiting.regs[reg]; ok {
+		return 0, fmt.Errorf("Car %v is already waiting", car.registration)
+	}
+	if err := carpark.checkWaiting(car); err != nil {
+		return 0, err
+	}
+	if carpark.waiting.mode == "fifo" {
+		priority = 0
+	}
+	carpark.waiting.arrivals++
+	entry := &waitingCar{car: car, priority: priority, arrival: carpark.waiting.arrivals}
+	carpark.waiting.queue.Push(entry)
+	carpark.waiting.regs[reg] = entry
+	carpark.track(&queueing{entry: entry})
+	for i, waiting := range carpark.waitingCars() {
+		if waiting == entry {
+			return i + 1, nil
+		}
+	}
+	return 0, nil
+}
+
+//Remove the car with the given registration number from the waiting list
+func (carpark *Carpark) cancelWaiting(registration string) (*Car, error) {
+	if carpark.waiting == nil {
+		return nil, errors.New("No waiting list")
+	}
+	reg := normalizeRegistration(registration)
+	entry, ok := carpark.waiting.regs[reg]
+	if !ok {
+		return nil, fmt.Errorf("Car %v is not waiting", registration)
+	}
+	carpark.waiting.queue.Remove(entry)
+	delete(carpark.waiting.regs, reg)
+	return entry.car, nil
+}
+
+//Park waiting cars in the empty slots, in the order of the waiting list, skipping cars which fit no empty slot
+func (carpark *Carpark) admitWaiting() []*Car {
+	if carpark.waiting == nil {
+		return nil
+	}
+	carpark.expireReservations()
+	var admitted []*Car
+	for _, entry := range carpark.waitingCars() {
+		if len(carpark.Map)+len(carpark.closed)+len(carpark.reserved) == carpark.maxSlot {
+			break
+		}
+		//Drop cars which can never be parked, such as cars which have since parked without waiting
+		if err := carpark.checkWaiting(entry.car); err != nil {
+			carpark.dropWaiting(entry)
+			continue
+		}
+		if _, err := carpark.insertCar(entry.car); err != nil {
+			continue
+		}
+		carpark.dropWaiting(entry)
+		admitted = append(admitted, entry.car)
+	}
+	return admitted
+}
+
+//Check that a car may wait for a slot, which it may not when it is already parked or comes through an unknown entrance
+func (carpark *Carpark) checkWaiting(car *Car) error {
+	if _, ok := carpark.regs[normalizeRegistration(car.registration)]; ok {
+		return fmt.Errorf("Car %v is already parked", car.registration)
+	}
+	if _, ok := carpark.entrances[car.gate]; car.gate != "" && !ok {
+		return fmt.Errorf("Unknown entrance %v", car.gate)
+	}
+	return nil
+}
+
+//Take a car off the waiting list
+func (carpark *Carpark) dropWaiting(entry *waitingCar) {
+	carpark.waiting.queue.Remove(entry)
+	delete(carpark.waiting.regs, normalizeRegistration(entry.car.registration))
+	carpark.track(&admission{entry: entry})
+}
+
+//Retrieve the waiting cars in the order they are served
+func (carpark *Carpark) waitingCars() []*waitingCar {
+	if carpark.waiting == nil {
+		return nil
+	}
+	return carpark.waiting.queue.Values()
+}
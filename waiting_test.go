@@ -0,0 +1,187 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+//waitingRegistrations returns the registration numbers of the waiting cars in the order they are served
+func waitingRegistrations(carpark *Carpark) []string {
+	var registrations []string
+	for _, waiting := range carpark.waitingCars() {
+		registrations = append(registrations, waiting.car.registration)
+	}
+	return registrations
+}
+
+func TestCarpark_enqueue(t *testing.T) {
+	type args struct {
+		registration string
+		priority     int
+	}
+	tests := []struct {
+		name              string
+		mode              string
+		args              []args
+		wantPositions     []int
+		wantErr           []bool
+		wantRegistrations []string
+	}{
+		{name: "First-in first-out",
+			mode:              "fifo",
+			args:              []args{{"KA-01-HH-1234", 0}, {"KA-01-HH-9999", 5}, {"KA-01-BB-0001", 0}},
+			wantPositions:     []int{1, 2, 3},
+			wantErr:           []bool{false, false, false},
+			wantRegistrations: []string{"KA-01-HH-1234", "KA-01-HH-9999", "KA-01-BB-0001"},
+		},
+		{name: "Priority",
+			mode:              "priority",
+			args:              []args{{"KA-01-HH-1234", 0}, {"KA-01-HH-9999", 5}, {"KA-01-BB-0001", 0}, {"KA-01-HH-7777", 5}},
+			wantPositions:     []int{1, 1, 3, 2},
+			wantErr:           []bool{false, false, false, false},
+			wantRegistrations: []string{"KA-01-HH-9999", "KA-01-HH-7777", "KA-01-HH-1234", "KA-01-BB-0001"},
+		},
+		{name: "Car already waiting",
+			mode:              "fifo",
+			args:              []args{{"KA-01-HH-1234", 0}, {"KA01HH1234", 0}},
+			wantPositions:     []int{1, 0},
+			wantErr:           []bool{false, true},
+			wantRegistrations: []string{"KA-01-HH-1234"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carpark := indexed(&Carpark{Map: values().map0, maxSlot: 1})
+			if err := carpark.setWaitingList(tt.mode); err != nil {
+				t.Fatalf("Carpark.setWaitingList() error = %v", err)
+			}
+			for i, args := range tt.args {
+				got, err := carpark.enqueue(&Car{registration: args.registration}, args.priority)
+				if (err != nil) != tt.wantErr[i] {
+					t.Errorf("Carpark.enqueue() error = %v, wantErr %v", err, tt.wantErr[i])
+				}
+				if got != tt.wantPositions[i] {
+					t.Errorf("Carpark.enqueue() = %v, want %v", got, tt.wantPositions[i])
+				}
+			}
+			if got := waitingRegistrations(carpark); !reflect.DeepEqual(got, tt.wantRegistrations) {
+				t.Errorf("waiting list = %v, want %v", got, tt.wantRegistrations)
+			}
+		})
+	}
+}
+
+func TestCarpark_admitWaiting(t *testing.T) {
+	tests := []struct {
+		name              string
+		carpark           *Carpark
+		waiting           []*Car
+		wantAdmitted      []int
+		wantRegistrations []string
+	}{
+		{name: "No waiting list",
+			carpark:      indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 2}),
+			wantAdmitted: nil,
+		},
+		{name: "Admit the head of the waiting list",
+			carpark:           indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 2}),
+			waiting:           []*Car{{registration: "KA-01-HH-2701"}, {registration: "KA-01-HH-9999"}},
+			wantAdmitted:      []int{2},
+			wantRegistrations: []string{"KA-01-HH-9999"},
+		},
+		{name: "Skip a car which fits no empty slot",
+			carpark:           indexed(&Carpark{Map: values().map1, emptySlot: values().emptySlot0, highestSlot: 1, maxSlot: 3, slotClass: map[int]VehicleClass{2: motorcycleClass}}),
+			waiting:           []*Car{{registration: "KA-01-HH-2701", class: busClass}, {registration: "KA-01-HH-9999", class: motorcycleClass}, {registration: "KA-01-HH-7777"}},
+			wantAdmitted:      []int{2, 3},
+			wantRegistrations: []string{"KA-01-HH-2701"},
+		},
+		{name: "Carpark still full",
+			carpark:           indexed(&Carpark{Map: values().mapAll, emptySlot: values().emptySlot0, highestSlot: 2, maxSlot: 2}),
+			waiting:           []*Car{{registration: "KA-01-HH-2701"}},
+			wantAdmitted:      nil,
+			wantRegistrations: []string{"KA-01-HH-2701"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.carpark.slotUses = map[int]int{}
+			if tt.waiting != nil {
+				if err := tt.carpark.setWaitingList("fifo"); err != nil {
+					t.Fatalf("Carpark.setWaitingList() error = %v", err)
+				}
+			}
+			for _, car := range tt.waiting {
+				tt.carpark.enqueue(car, 0)
+			}
+			var got []int
+			for _, car := range tt.carpark.admitWaiting() {
+				got = append(got, car.slot)
+			}
+			if !reflect.DeepEqual(got, tt.wantAdmitted) {
+				t.Errorf("Carpark.admitWaiting() = %v, want %v", got, tt.wantAdmitted)
+			}
+			if got := waitingRegistrations(tt.carpark); !reflect.DeepEqual(got, tt.wantRegistrations) {
+				t.Errorf("waiting list = %v, want %v", got, tt.wantRegistrations)
+			}
+		})
+	}
+}
+
+func TestCarpark_admitWaitingDrop(t *testing.T) {
+	carpark := &Carpark{}
+	steps := []error{carpark.init(2), carpark.addEntrance("B", 2), carpark.setWaitingList("fifo")}
+	_, err := carpark.insertCar(&Car{registration: "KA-01-HH-1234"})
+	steps = append(steps, err)
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("step %v error = %v", i, err)
+		}
+	}
+	//Cars which can never be parked do not join the waiting list
+	for _, car := range []*Car{{registration: "KA01HH1234"}, {registration: "KA-01-HH-9999", gate: "C"}} {
+		if _, err := carpark.enqueue(car, 0); err == nil {
+			t.Errorf("Carpark.enqueue(%v) error = nil, want error", car.registration)
+		}
+	}
+	//Cars which parked or whose entrance was removed while waiting are dropped from the waiting list
+	carpark.enqueue(&Car{registration: "KA-01-HH-9999"}, 0)
+	carpark.enqueue(&Car{registration: "KA-01-BB-0001", gate: "B"}, 0)
+	carpark.enqueue(&Car{registration: "KA-01-HH-7777"}, 0)
+	if _, err := carpark.insertCar(&Car{registration: "KA-01-HH-9999"}); err != nil {
+		t.Fatalf("Carpark.insertCar() error = %v", err)
+	}
+	carpark.removeCar(1)
+	delete(carpark.entrances, "B")
+	var got []string
+	for _, car := range carpark.admitWaiting() {
+		got = append(got, car.registration)
+	}
+	if want := []string{"KA-01-HH-7777"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Carpark.admitWaiting() = %v, want %v", got, want)
+	}
+	if got := waitingRegistrations(carpark); got != nil {
+		t.Errorf("waiting list = %v, want empty", got)
+	}
+}
+
+func TestCarpark_cancelWaiting(t *testing.T) {
+	carpark := indexed(&Carpark{Map: values().map0, maxSlot: 1})
+	if _, err := carpark.cancelWaiting("KA-01-HH-1234"); err == nil {
+		t.Errorf("Carpark.cancelWaiting() without a waiting list error = nil, want error")
+	}
+	carpark.setWaitingList("fifo")
+	carpark.enqueue(&Car{registration: "KA-01-HH-1234"}, 0)
+	carpark.enqueue(&Car{registration: "KA-01-HH-9999"}, 0)
+	if err := carpark.setWaitingList("priority"); err == nil {
+		t.Errorf("Carpark.setWaitingList() of a non-empty waiting list error = nil, want error")
+	}
+	if car, err := carpark.cancelWaiting("ka01hh1234"); err != nil || car.registration != "KA-01-HH-1234" {
+		t.Errorf("Carpark.cancelWaiting() = %v, %v, want KA-01-HH-1234", car, err)
+	}
+	if _, err := carpark.cancelWaiting("KA-01-HH-1234"); err == nil {
+		t.Errorf("Carpark.cancelWaiting() of a car not waiting error = nil, want error")
+	}
+	if got, want := waitingRegistrations(carpark), []string{"KA-01-HH-9999"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("waiting list = %v, want %v", got, want)
+	}
+}